@@ -11,7 +11,10 @@ import (
 
 type Config struct {
 	// 服务器配置
-	Port string
+	Port            string
+	PublicBaseURL   string // 对外访问地址（如 https://img.example.com），为空时按请求推导
+	ShutdownTimeout int    // 优雅退出时等待进行中请求完成的最长时间（秒）
+	JobsStopTimeout int    // HTTP 服务停止后等待后台任务完成的最长时间（秒），单独计时
 
 	// 鉴权配置
 	JWTSecret       string
@@ -19,6 +22,10 @@ type Config struct {
 	DefaultAdmin    string
 	DefaultPassword string
 
+	// URL 签名配置：为空时使用 JWTSecret
+	URLSigningKey   string
+	SignedURLMaxTTL int // 签名 URL 最长有效期（秒）
+
 	// 数据库配置
 	DBHost     string
 	DBPort     string
@@ -26,6 +33,9 @@ type Config struct {
 	DBPassword string
 	DBName     string
 
+	// 存储配置：local（本地磁盘）或 s3（R2/MinIO 等 S3 兼容存储）
+	StorageDriver string
+
 	// Cloudflare R2 配置
 	R2AccountID       string
 	R2AccessKeyID     string
@@ -42,9 +52,43 @@ type Config struct {
 	RedisDB       int
 
 	// 上传配置
-	MaxFileSize  int64
-	AllowedTypes []string
-	UploadPath   string
+	MaxFileSize    int64
+	MaxImagePixels int64 // 宽×高上限，解码前校验以防解压炸弹
+	AllowedTypes   []string
+	UploadPath     string
+
+	// 回收站保留天数，超过后由后台任务彻底删除（0 表示不自动清理）
+	TrashRetentionDays int
+
+	// 归档导入限制
+	ImportMaxEntries   int   // 单个归档最多文件数
+	ImportMaxTotalSize int64 // 单个归档解压后总字节数上限
+	ImportConcurrency  int   // 并发处理的文件数
+
+	// 远程地址导入限制（大小沿用 MAX_FILE_SIZE）
+	RemoteFetchTimeout      int // 下载总耗时上限（秒）
+	RemoteFetchMaxRedirects int
+
+	// 默认配额（0 表示不限，可按用户或游客码覆盖；admin 不受限制）
+	QuotaMaxBytes  int64
+	QuotaMaxImages int64
+	QuotaMaxDaily  int64
+
+	// 元数据策略：strip（旋正并移除全部元数据）/ orient（旋正，保留其余元数据）/ keep（原样保留）
+	MetadataPolicy string
+	SaveExif       bool // 是否将相机、拍摄时间写入图片记录
+	SaveExifGPS    bool // 是否同时保存 GPS 坐标（需 SaveExif）
+
+	// 防盗链配置（/img 与 /uploads）
+	HotlinkProtection        bool
+	HotlinkAllowedDomains    []string // 允许的来源域名（含子域名），本站域名始终允许
+	HotlinkAllowEmptyReferer bool
+	HotlinkPlaceholder       string // 被拦截时返回的占位图文件路径，为空时返回 403
+
+	// 图片变换配置（/img/:uuid）
+	TransformCachePath     string
+	TransformCacheMaxBytes int64
+	TransformPresets       string // 形如 name:w=300&h=300&fit=cover;name2:w=640
 }
 
 var AppConfig *Config
@@ -56,8 +100,22 @@ func LoadConfig() {
 	}
 
 	redisDB, _ := strconv.Atoi(getEnv("REDIS_DB", "0"))
-	maxFileSize, _ := strconv.ParseInt(getEnv("MAX_FILE_SIZE", "10485760"), 10, 64) // 10MB
+	maxFileSize, _ := strconv.ParseInt(getEnv("MAX_FILE_SIZE", "10485760"), 10, 64)       // 10MB
+	maxImagePixels, _ := strconv.ParseInt(getEnv("MAX_IMAGE_PIXELS", "40000000"), 10, 64) // 4000 万像素
 	jwtExpireHours, _ := strconv.Atoi(getEnv("JWT_EXPIRE_HOURS", "72"))
+	signedURLMaxTTL, _ := strconv.Atoi(getEnv("SIGNED_URL_MAX_TTL", "604800")) // 7 天
+	quotaMaxBytes, _ := strconv.ParseInt(getEnv("QUOTA_MAX_BYTES", "0"), 10, 64)
+	quotaMaxImages, _ := strconv.ParseInt(getEnv("QUOTA_MAX_IMAGES", "0"), 10, 64)
+	quotaMaxDaily, _ := strconv.ParseInt(getEnv("QUOTA_MAX_DAILY_UPLOADS", "0"), 10, 64)
+	trashRetentionDays, _ := strconv.Atoi(getEnv("TRASH_RETENTION_DAYS", "30"))
+	importMaxEntries, _ := strconv.Atoi(getEnv("IMPORT_MAX_ENTRIES", "1000"))
+	importMaxTotalSize, _ := strconv.ParseInt(getEnv("IMPORT_MAX_TOTAL_SIZE", "1073741824"), 10, 64) // 1GB
+	importConcurrency, _ := strconv.Atoi(getEnv("IMPORT_CONCURRENCY", "4"))
+	remoteFetchTimeout, _ := strconv.Atoi(getEnv("REMOTE_FETCH_TIMEOUT", "15"))
+	remoteFetchMaxRedirects, _ := strconv.Atoi(getEnv("REMOTE_FETCH_MAX_REDIRECTS", "3"))
+	shutdownTimeout, _ := strconv.Atoi(getEnv("SHUTDOWN_TIMEOUT", "30"))
+	jobsStopTimeout, _ := strconv.Atoi(getEnv("SHUTDOWN_JOBS_TIMEOUT", "10"))
+	transformCacheMaxBytes, _ := strconv.ParseInt(getEnv("TRANSFORM_CACHE_MAX_BYTES", "536870912"), 10, 64) // 512MB
 
 	// 端口与允许类型（从环境变量解析）
 	port := getEnv("SERVER_PORT", getEnv("PORT", "8080"))
@@ -72,7 +130,10 @@ func LoadConfig() {
 
 	AppConfig = &Config{
 		// 服务器配置
-		Port: port,
+		Port:            port,
+		PublicBaseURL:   strings.TrimRight(getEnv("PUBLIC_BASE_URL", ""), "/"),
+		ShutdownTimeout: shutdownTimeout,
+		JobsStopTimeout: jobsStopTimeout,
 
 		// 鉴权配置
 		JWTSecret:       getEnv("JWT_SECRET", "change_me_secret"),
@@ -80,6 +141,10 @@ func LoadConfig() {
 		DefaultAdmin:    getEnv("DEFAULT_ADMIN", "root"),
 		DefaultPassword: getEnv("DEFAULT_PASSWORD", "123456"),
 
+		// URL 签名配置
+		URLSigningKey:   getEnv("URL_SIGNING_KEY", ""),
+		SignedURLMaxTTL: signedURLMaxTTL,
+
 		// 数据库配置
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "3306"),
@@ -87,7 +152,10 @@ func LoadConfig() {
 		DBPassword: getEnv("DB_PASSWORD", ""),
 		DBName:     getEnv("DB_NAME", "image_host"),
 
-		// Cloudflare R2 配置（保留结构以兼容，但不强制使用）
+		// 存储配置
+		StorageDriver: strings.ToLower(getEnv("STORAGE_DRIVER", "local")),
+
+		// Cloudflare R2 / S3 兼容存储配置（STORAGE_DRIVER=s3 时生效）
 		R2AccountID:       getEnv("R2_ACCOUNT_ID", ""),
 		R2AccessKeyID:     getEnv("R2_ACCESS_KEY_ID", ""),
 		R2SecretAccessKey: getEnv("R2_SECRET_ACCESS_KEY", ""),
@@ -103,9 +171,44 @@ func LoadConfig() {
 		RedisDB:       redisDB,
 
 		// 上传配置
-		MaxFileSize:  maxFileSize,
-		AllowedTypes: allowedTypes,
-		UploadPath:   getEnv("UPLOAD_PATH", "./uploads"),
+		MaxFileSize:    maxFileSize,
+		MaxImagePixels: maxImagePixels,
+		AllowedTypes:   allowedTypes,
+		UploadPath:     getEnv("UPLOAD_PATH", "./uploads"),
+
+		// 回收站
+		TrashRetentionDays: trashRetentionDays,
+
+		// 归档导入
+		ImportMaxEntries:   importMaxEntries,
+		ImportMaxTotalSize: importMaxTotalSize,
+		ImportConcurrency:  importConcurrency,
+
+		// 远程地址导入
+		RemoteFetchTimeout:      remoteFetchTimeout,
+		RemoteFetchMaxRedirects: remoteFetchMaxRedirects,
+
+		// 默认配额
+		QuotaMaxBytes:  quotaMaxBytes,
+		QuotaMaxImages: quotaMaxImages,
+		QuotaMaxDaily:  quotaMaxDaily,
+
+		// 元数据配置
+		MetadataPolicy: strings.ToLower(getEnv("METADATA_POLICY", "strip")),
+		SaveExif:       getEnv("SAVE_EXIF", "false") == "true",
+		SaveExifGPS:    getEnv("SAVE_EXIF_GPS", "false") == "true",
+
+		// 防盗链配置
+		HotlinkProtection:        getEnv("HOTLINK_PROTECTION", "false") == "true",
+		HotlinkAllowedDomains:    splitList(getEnv("HOTLINK_ALLOWED_DOMAINS", "")),
+		HotlinkAllowEmptyReferer: getEnv("HOTLINK_ALLOW_EMPTY_REFERER", "true") == "true",
+		HotlinkPlaceholder:       getEnv("HOTLINK_PLACEHOLDER", ""),
+
+		// 图片变换配置
+		TransformCachePath:     getEnv("TRANSFORM_CACHE_PATH", "./cache/variants"),
+		TransformCacheMaxBytes: transformCacheMaxBytes,
+		TransformPresets: getEnv("TRANSFORM_PRESETS",
+			"thumb:w=300&h=300&fit=cover;small:w=640;medium:w=1280;large:w=1920;webp:w=1280&fmt=webp"),
 	}
 }
 
@@ -115,3 +218,14 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// splitList 解析逗号分隔的列表，忽略空项
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
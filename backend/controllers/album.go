@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"image-host/database"
+	"image-host/models"
+	"image-host/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AlbumController struct{}
+
+var Album = &AlbumController{}
+
+type albumRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	CoverUUID   *string `json:"cover_uuid"` // 空字符串表示清除封面
+}
+
+type albumImagesRequest struct {
+	UUIDs []string `json:"uuids"`
+}
+
+// List 列出相册（非管理员仅自己的）
+// GET /api/v1/albums
+func (ac *AlbumController) List(c *gin.Context) {
+	var albums []models.Album
+	q := database.DB.Order("id DESC")
+	if !isAdmin(c) {
+		q = q.Where("owner = ?", c.GetString("username"))
+	}
+	if err := q.Find(&albums).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch albums", "code": "DATABASE_ERROR"})
+		return
+	}
+	services.Albums.Fill(albums)
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": albums})
+}
+
+// Create 创建相册
+// POST /api/v1/albums  { name, description? }
+func (ac *AlbumController) Create(c *gin.Context) {
+	var req albumRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == nil || !validAlbumName(*req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "code": "INVALID_PAYLOAD"})
+		return
+	}
+	album := &models.Album{
+		Name:  strings.TrimSpace(*req.Name),
+		Owner: c.GetString("username"),
+	}
+	if req.Description != nil {
+		album.Description = *req.Description
+	}
+	if err := database.DB.Create(album).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create album", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": album})
+}
+
+// Get 相册详情，images 按相册内顺序排列
+// GET /api/v1/albums/:id
+func (ac *AlbumController) Get(c *gin.Context) {
+	album, ok := ac.findOwned(c)
+	if !ok {
+		return
+	}
+	var images []models.Image
+	if err := database.DB.
+		Joins("JOIN album_images ON album_images.image_id = images.id").
+		Where("album_images.album_id = ?", album.ID).
+		Order("album_images.position ASC").
+		Find(&images).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images", "code": "DATABASE_ERROR"})
+		return
+	}
+	albums := []models.Album{*album}
+	services.Albums.Fill(albums)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"album":  albums[0],
+			"images": images,
+		},
+	})
+}
+
+// Update 修改名称、描述或封面
+// PUT /api/v1/albums/:id  { name?, description?, cover_uuid? }
+func (ac *AlbumController) Update(c *gin.Context) {
+	album, ok := ac.findOwned(c)
+	if !ok {
+		return
+	}
+	var req albumRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "code": "INVALID_PAYLOAD"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		if !validAlbumName(*req.Name) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid name", "code": "INVALID_PAYLOAD"})
+			return
+		}
+		updates["name"] = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.CoverUUID != nil {
+		if *req.CoverUUID == "" {
+			updates["cover_image_id"] = nil
+		} else {
+			// 封面必须是相册内的图片
+			var image models.Image
+			if err := database.DB.
+				Joins("JOIN album_images ON album_images.image_id = images.id").
+				Where("album_images.album_id = ? AND images.uuid = ?", album.ID, *req.CoverUUID).
+				First(&image).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Cover image is not in this album", "code": "INVALID_COVER"})
+				return
+			}
+			updates["cover_image_id"] = image.ID
+		}
+	}
+	if len(updates) > 0 {
+		if err := database.DB.Model(album).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update album", "code": "DATABASE_ERROR"})
+			return
+		}
+		database.DB.First(album, album.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": album})
+}
+
+// Delete 删除相册（不删除其中的图片）
+// DELETE /api/v1/albums/:id
+func (ac *AlbumController) Delete(c *gin.Context) {
+	album, ok := ac.findOwned(c)
+	if !ok {
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("album_id = ?", album.ID).Delete(&models.AlbumImage{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(album).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete album", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// AddImages 添加图片到相册末尾（非管理员只能添加自己的图片）
+// POST /api/v1/albums/:id/images  { uuids: [] }
+func (ac *AlbumController) AddImages(c *gin.Context) {
+	album, ok := ac.findOwned(c)
+	if !ok {
+		return
+	}
+	var req albumImagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.UUIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "code": "INVALID_PAYLOAD"})
+		return
+	}
+	ids, missing := ownedImageIDs(c, req.UUIDs)
+	added, err := services.Albums.AddImages(album.ID, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add images", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"added":   added,
+			"missing": missing,
+		},
+	})
+}
+
+// RemoveImage 从相册移除图片
+// DELETE /api/v1/albums/:id/images/:uuid
+func (ac *AlbumController) RemoveImage(c *gin.Context) {
+	album, ok := ac.findOwned(c)
+	if !ok {
+		return
+	}
+	var image models.Image
+	if err := database.DB.Where("uuid = ?", c.Param("uuid")).First(&image).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found", "code": "NOT_FOUND"})
+		return
+	}
+	if err := services.Albums.RemoveImage(album.ID, image.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove image", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// Reorder 调整相册内顺序
+// PUT /api/v1/albums/:id/order  { uuids: [] }
+func (ac *AlbumController) Reorder(c *gin.Context) {
+	album, ok := ac.findOwned(c)
+	if !ok {
+		return
+	}
+	var req albumImagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.UUIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "code": "INVALID_PAYLOAD"})
+		return
+	}
+	var images []models.Image
+	database.DB.Where("uuid IN ?", req.UUIDs).Find(&images)
+	idByUUID := map[string]uint{}
+	for _, img := range images {
+		idByUUID[img.UUID] = img.ID
+	}
+	ids := make([]uint, 0, len(req.UUIDs))
+	for _, u := range req.UUIDs {
+		if id, ok := idByUUID[u]; ok {
+			ids = append(ids, id)
+		}
+	}
+	if err := services.Albums.Reorder(album.ID, ids); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder album", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// findOwned 按 :id 查找当前用户可访问的相册，失败时已写入响应
+func (ac *AlbumController) findOwned(c *gin.Context) (*models.Album, bool) {
+	var album models.Album
+	q := database.DB.Where("id = ?", c.Param("id"))
+	if !isAdmin(c) {
+		q = q.Where("owner = ?", c.GetString("username"))
+	}
+	if err := q.First(&album).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Album not found", "code": "NOT_FOUND"})
+		return nil, false
+	}
+	return &album, true
+}
+
+// ownedImageIDs 将 UUID 转为当前用户可操作的图片 ID，返回无法访问的 UUID
+func ownedImageIDs(c *gin.Context, uuids []string) ([]uint, []string) {
+	var images []models.Image
+	q := database.DB.Where("uuid IN ?", uuids)
+	if !isAdmin(c) {
+		q = q.Where("uploader = ?", c.GetString("username"))
+	}
+	q.Find(&images)
+
+	idByUUID := map[string]uint{}
+	for _, img := range images {
+		idByUUID[img.UUID] = img.ID
+	}
+	ids := make([]uint, 0, len(uuids))
+	missing := []string{}
+	for _, u := range uuids {
+		if id, ok := idByUUID[u]; ok {
+			ids = append(ids, id)
+		} else {
+			missing = append(missing, u)
+		}
+	}
+	return ids, missing
+}
+
+func validAlbumName(name string) bool {
+	name = strings.TrimSpace(name)
+	return name != "" && len(name) <= 128
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"image-host/config"
 	"image-host/database"
 	"image-host/models"
+	"image-host/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -48,6 +50,10 @@ func (a *AuthController) Login(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Username or password incorrect"})
 		return
 	}
+	if user.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled", "code": "ACCOUNT_DISABLED"})
+		return
+	}
 
 	exp := time.Now().Add(time.Duration(config.AppConfig.JWTExpireHours) * time.Hour)
 	claims := jwt.RegisteredClaims{
@@ -56,6 +62,7 @@ func (a *AuthController) Login(c *gin.Context) {
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"username": user.Username,
+		"ver":      user.TokenVersion,
 		"exp":      claims.ExpiresAt.Unix(),
 		"iat":      claims.IssuedAt.Unix(),
 	})
@@ -69,14 +76,29 @@ func (a *AuthController) Login(c *gin.Context) {
 		"data": gin.H{
 			"token":    tokenStr,
 			"username": user.Username,
+			"role":     user.Role,
 			"expires":  exp.Unix(),
 		},
 	})
 }
 
+// Me 当前身份及配额用量
 func (a *AuthController) Me(c *gin.Context) {
 	username := c.GetString("username")
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"username": username}})
+	role := c.GetString("role")
+	usage, err := services.Quotas.Usage(username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch usage", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"username": username,
+		"role":     role,
+		"quota": gin.H{
+			"limits": services.Quotas.Limits(username, role),
+			"usage":  usage,
+		},
+	}})
 }
 
 // GuestLogin 游客码登录，返回用户名形如 guest:<id>
@@ -89,15 +111,21 @@ func (a *AuthController) GuestLogin(c *gin.Context) {
 	// 兼容大小写/多余空格
 	req.Code = strings.ToUpper(strings.TrimSpace(req.Code))
 
-	var gc models.GuestCode
-	// 允许永久（ExpiresAt 为空）或未过期
-	if err := database.DB.Where("code = ?", req.Code).First(&gc).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
-		return
-	}
-	if gc.ExpiresAt != nil && time.Now().After(*gc.ExpiresAt) {
+	// 校验有效期、停用状态与登录次数
+	gc, err := services.Guest.Login(req.Code)
+	switch {
+	case errors.Is(err, services.ErrGuestCodeExpired):
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Code expired"})
 		return
+	case errors.Is(err, services.ErrGuestCodeDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Code disabled", "code": "GUEST_CODE_DISABLED"})
+		return
+	case errors.Is(err, services.ErrGuestLoginLimit):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Code login limit reached", "code": "GUEST_LOGIN_LIMIT"})
+		return
+	case err != nil:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
 	}
 
 	// JWT：username = guest:<id>
@@ -121,6 +149,7 @@ func (a *AuthController) GuestLogin(c *gin.Context) {
 		"data": gin.H{
 			"token":    tokenStr,
 			"username": "guest",
+			"role":     models.RoleGuest,
 			"expires":  exp.Unix(),
 		},
 	})
@@ -0,0 +1,314 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"image-host/database"
+	"image-host/models"
+	"image-host/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type BulkController struct{}
+
+var Bulk = &BulkController{}
+
+// MaxBulkItems 单次批量操作的图片数量上限
+const MaxBulkItems = 1000
+
+// 批量操作类型
+const (
+	bulkDelete        = "delete"
+	bulkMoveToAlbum   = "move_to_album"
+	bulkAddTags       = "add_tags"
+	bulkRemoveTags    = "remove_tags"
+	bulkSetVisibility = "set_visibility"
+	bulkTransfer      = "transfer"
+)
+
+// bulkRequest 批量操作：uuids 与 filter 二选一；filter 使用与列表相同的查询参数名
+type bulkRequest struct {
+	Action      string                 `json:"action"`
+	UUIDs       []string               `json:"uuids"`
+	Filter      map[string]interface{} `json:"filter"`
+	AlbumID     uint                   `json:"album_id"`      // move_to_album：目标相册
+	FromAlbumID uint                   `json:"from_album_id"` // move_to_album：可选，同时从该相册移除
+	Tags        []string               `json:"tags"`          // add_tags / remove_tags
+	Visibility  string                 `json:"visibility"`    // set_visibility
+	Uploader    string                 `json:"uploader"`      // transfer：新的上传者（账号名或 guest:<id>）
+}
+
+// bulkResult 单张图片的处理结果
+type bulkResult struct {
+	UUID    string `json:"uuid"`
+	Success bool   `json:"success"`
+	Code    string `json:"code,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Apply 对一组图片执行批量操作，逐项返回结果；非 admin 仅能操作自己上传的图片
+// POST /api/v1/images/bulk
+func (bc *BulkController) Apply(c *gin.Context) {
+	var req bulkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "code": "INVALID_PAYLOAD"})
+		return
+	}
+	if (len(req.UUIDs) == 0) == (req.Filter == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exactly one of uuids or filter is required", "code": "INVALID_PAYLOAD"})
+		return
+	}
+	if len(req.UUIDs) > MaxBulkItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d images per request", MaxBulkItems), "code": "TOO_MANY_ITEMS"})
+		return
+	}
+
+	op, ok := bc.prepare(c, &req)
+	if !ok {
+		return
+	}
+
+	images, results, ok := bc.collect(c, &req)
+	if !ok {
+		return
+	}
+
+	succeeded := 0
+	for i := range images {
+		img := &images[i]
+		r := bulkResult{UUID: img.UUID, Success: true}
+		if err := op(img); err != nil {
+			code := "OPERATION_FAILED"
+			var qe *services.QuotaExceededError
+			if errors.As(err, &qe) {
+				code = "QUOTA_EXCEEDED"
+			}
+			r = bulkResult{UUID: img.UUID, Code: code, Error: err.Error()}
+		} else {
+			succeeded++
+		}
+		results = append(results, r)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"action":    req.Action,
+			"total":     len(results),
+			"succeeded": succeeded,
+			"failed":    len(results) - succeeded,
+			"results":   results,
+		},
+	})
+}
+
+// prepare 校验操作参数与权限范围，返回作用于单张图片的操作
+func (bc *BulkController) prepare(c *gin.Context, req *bulkRequest) (func(*models.Image) error, bool) {
+	scope := models.ScopeUpload
+	if req.Action == bulkDelete {
+		scope = models.ScopeDelete
+	}
+	if scopes, isToken := c.Get("token_scopes"); isToken && !services.HasScope(scopes.(string), scope) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Token lacks required scope: " + scope, "code": "INSUFFICIENT_SCOPE"})
+		return nil, false
+	}
+	invalid := func(msg string) (func(*models.Image) error, bool) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "code": "INVALID_PAYLOAD"})
+		return nil, false
+	}
+
+	switch req.Action {
+	case bulkDelete:
+		return services.Library.Trash, true
+
+	case bulkMoveToAlbum:
+		target, ok := bc.ownedAlbum(c, req.AlbumID)
+		if !ok {
+			return nil, false
+		}
+		var from uint
+		if req.FromAlbumID != 0 {
+			album, ok := bc.ownedAlbum(c, req.FromAlbumID)
+			if !ok {
+				return nil, false
+			}
+			from = album.ID
+		}
+		return func(img *models.Image) error {
+			if _, err := services.Albums.AddImages(target.ID, []uint{img.ID}); err != nil {
+				return err
+			}
+			if from != 0 && from != target.ID {
+				return services.Albums.RemoveImage(from, img.ID)
+			}
+			return nil
+		}, true
+
+	case bulkAddTags, bulkRemoveTags:
+		names, err := services.NormalizeTags(req.Tags)
+		if err != nil {
+			return invalid(err.Error())
+		}
+		if len(names) == 0 {
+			return invalid("Tags are required")
+		}
+		if req.Action == bulkAddTags {
+			return func(img *models.Image) error { return services.Tags.Add([]uint{img.ID}, names) }, true
+		}
+		return func(img *models.Image) error { return services.Tags.Remove([]uint{img.ID}, names) }, true
+
+	case bulkSetVisibility:
+		if !models.ValidVisibility(req.Visibility) {
+			return invalid("Invalid visibility")
+		}
+		return func(img *models.Image) error {
+			return database.DB.Model(img).Update("visibility", req.Visibility).Error
+		}, true
+
+	case bulkTransfer:
+		if !isAdmin(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admin can transfer images", "code": "FORBIDDEN"})
+			return nil, false
+		}
+		target := strings.TrimSpace(req.Uploader)
+		role, ok := uploaderRole(target)
+		if !ok {
+			return invalid("Target uploader not found")
+		}
+		// 目标配额与转移在同一事务中校验；图片移出原上传者的相册，分享链接改归目标管理
+		return func(img *models.Image) error {
+			if img.Uploader == target {
+				return nil
+			}
+			return services.Quotas.Assign(target, role, img.FileSize, func(tx *gorm.DB) error {
+				return services.Library.Transfer(tx, img, target)
+			})
+		}, true
+	}
+	return invalid("Unknown action")
+}
+
+// collect 按 uuids 或 filter 取得可操作的图片；uuids 中不存在或无权访问的项直接记为失败
+func (bc *BulkController) collect(c *gin.Context, req *bulkRequest) ([]models.Image, []bulkResult, bool) {
+	results := []bulkResult{}
+	var images []models.Image
+
+	if len(req.UUIDs) > 0 {
+		q := database.DB.Where("uuid IN ?", req.UUIDs)
+		// 非管理员只能操作自己的
+		if !isAdmin(c) {
+			q = q.Where("uploader = ?", c.GetString("username"))
+		}
+		var found []models.Image
+		if err := q.Find(&found).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images", "code": "DATABASE_ERROR"})
+			return nil, nil, false
+		}
+		byUUID := make(map[string]models.Image, len(found))
+		for _, img := range found {
+			byUUID[img.UUID] = img
+		}
+		seen := map[string]bool{}
+		for _, u := range req.UUIDs {
+			if seen[u] {
+				continue
+			}
+			seen[u] = true
+			if img, ok := byUUID[u]; ok {
+				images = append(images, img)
+			} else {
+				results = append(results, bulkResult{UUID: u, Code: "NOT_FOUND", Error: "Image not found"})
+			}
+		}
+		return images, results, true
+	}
+
+	filter, err := services.ParseImageFilter(filterValues(req.Filter))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "INVALID_FILTER"})
+		return nil, nil, false
+	}
+	if !Upload.scopeFilter(c, filter) {
+		return nil, nil, false
+	}
+	var total int64
+	if err := filter.Apply(database.DB.Model(&models.Image{})).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count images", "code": "DATABASE_ERROR"})
+		return nil, nil, false
+	}
+	if total > MaxBulkItems {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Filter matches %d images, at most %d per request", total, MaxBulkItems),
+			"code":  "TOO_MANY_ITEMS",
+		})
+		return nil, nil, false
+	}
+	if err := filter.Apply(database.DB).Order(filter.Order()).Find(&images).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images", "code": "DATABASE_ERROR"})
+		return nil, nil, false
+	}
+	return images, results, true
+}
+
+// ownedAlbum 查找当前用户可操作的相册
+func (bc *BulkController) ownedAlbum(c *gin.Context, id uint) (*models.Album, bool) {
+	var album models.Album
+	q := database.DB.Where("id = ?", id)
+	if !isAdmin(c) {
+		q = q.Where("owner = ?", c.GetString("username"))
+	}
+	if id == 0 || q.First(&album).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Album not found", "code": "NOT_FOUND"})
+		return nil, false
+	}
+	return &album, true
+}
+
+// uploaderRole 上传者（现有账号或游客码）的角色，不存在时 ok 为 false。
+// 已删除的游客码仍保留记录，其图片从回收站恢复后可以转回
+func uploaderRole(uploader string) (string, bool) {
+	if uploader == "" {
+		return "", false
+	}
+	if id, ok := strings.CutPrefix(uploader, "guest:"); ok {
+		var count int64
+		database.DB.Unscoped().Model(&models.GuestCode{}).Where("id = ?", id).Count(&count)
+		return models.RoleGuest, count > 0
+	}
+	var user models.User
+	if err := database.DB.Select("role").Where("username = ?", uploader).First(&user).Error; err != nil {
+		return "", false
+	}
+	return user.Role, true
+}
+
+// filterValues 将 JSON 形式的过滤条件转为查询参数，数组对应可重复参数（如 tag）
+func filterValues(filter map[string]interface{}) url.Values {
+	values := url.Values{}
+	var add func(key string, v interface{})
+	add = func(key string, v interface{}) {
+		switch val := v.(type) {
+		case string:
+			values.Add(key, val)
+		case float64:
+			values.Add(key, strconv.FormatFloat(val, 'f', -1, 64))
+		case bool:
+			values.Add(key, strconv.FormatBool(val))
+		case []interface{}:
+			for _, item := range val {
+				add(key, item)
+			}
+		}
+	}
+	for k, v := range filter {
+		add(k, v)
+	}
+	return values
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	"image-host/models"
+	"image-host/services"
+)
+
+func TestUploaderRole(t *testing.T) {
+	db := useTestDB(t)
+	if err := db.Create(&models.User{Username: "alice", PasswordHash: "x", Role: models.RoleMember}).Error; err != nil {
+		t.Fatal(err)
+	}
+	gc, err := services.Guest.GenerateCode("root", nil, services.GuestCodeOptions{}, models.QuotaLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	guest := fmt.Sprintf("guest:%d", gc.ID)
+
+	cases := []struct {
+		uploader string
+		role     string
+		ok       bool
+	}{
+		{"alice", models.RoleMember, true},
+		{guest, models.RoleGuest, true},
+		{"bob", "", false},
+		{"guest:999", models.RoleGuest, false},
+		{"", "", false},
+	}
+	check := func() {
+		for _, tc := range cases {
+			if role, ok := uploaderRole(tc.uploader); ok != tc.ok || (ok && role != tc.role) {
+				t.Errorf("uploaderRole(%q) = %q, %v; want %q, %v", tc.uploader, role, ok, tc.role, tc.ok)
+			}
+		}
+	}
+	check()
+
+	// 删除游客码后，其回收站中的图片恢复后仍可转回该游客码
+	if err := services.Guest.DeleteCodeAndImages(fmt.Sprint(gc.ID)); err != nil {
+		t.Fatal(err)
+	}
+	check()
+}
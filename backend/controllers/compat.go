@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"image-host/config"
+	"image-host/database"
+	"image-host/models"
+	"image-host/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompatController ShareX / PicGo / Typora 等工具的兼容接口，返回扁平 JSON
+type CompatController struct{}
+
+var Compat = &CompatController{}
+
+// Upload 兼容上传，文件字段为 file（或 image），鉴权见 middleware.APIKeyAuth
+// POST /api/v1/compat/upload
+// 响应：{ success, url, thumbnail_url, deletion_url, uuid, filename }
+func (cc *CompatController) Upload(c *gin.Context) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		file, header, err = c.Request.FormFile("image")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Failed to get uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	image, uerr := Upload.storeUpload(c, file, header)
+	if uerr != nil {
+		c.JSON(uerr.status, gin.H{"success": false, "error": uerr.message})
+		return
+	}
+
+	base := publicBaseURL(c)
+	thumbnailURL := image.ThumbnailURL
+	if thumbnailURL == "" {
+		thumbnailURL = image.PublicURL
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"uuid":          image.UUID,
+		"filename":      image.OriginalName,
+		"url":           absoluteURL(base, image.PublicURL),
+		"thumbnail_url": absoluteURL(base, thumbnailURL),
+		"deletion_url":  base + "/api/v1/compat/delete/" + image.UUID + "/" + services.DeletionSignature(image.UUID),
+	})
+}
+
+// Delete 通过签名链接删除图片（无需登录，链接由上传响应返回）
+// GET /api/v1/compat/delete/:uuid/:sig
+func (cc *CompatController) Delete(c *gin.Context) {
+	uuid := c.Param("uuid")
+	if !services.VerifyDeletionSignature(uuid, c.Param("sig")) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Invalid deletion link"})
+		return
+	}
+	var image models.Image
+	if err := database.DB.Where("uuid = ?", uuid).First(&image).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Image not found"})
+		return
+	}
+	if err := services.Library.Trash(&image); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to delete image"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// Config 为当前用户生成工具配置文件，同时创建一个仅含 upload/delete 权限的新 API token；
+// 此前为同一工具生成的 token 随之吊销，重复下载不会累积未使用的 token
+// POST /api/v1/compat/config/:client   client = sharex | picgo | typora
+func (cc *CompatController) Config(c *gin.Context) {
+	client := strings.ToLower(c.Param("client"))
+	if client != "sharex" && client != "picgo" && client != "typora" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported client", "code": "INVALID_CLIENT"})
+		return
+	}
+
+	plain, _, err := services.Tokens.Rotate(c.GetString("username"), client+" config", models.ScopeUpload+","+models.ScopeDelete)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token", "code": "DATABASE_ERROR"})
+		return
+	}
+	uploadURL := publicBaseURL(c) + "/api/v1/compat/upload"
+
+	var body interface{}
+	var filename string
+	if client == "sharex" {
+		filename = "imgtourl.sxcu"
+		body = gin.H{
+			"Version":         "15.0.0",
+			"Name":            "ImgToUrl",
+			"DestinationType": "ImageUploader",
+			"RequestMethod":   "POST",
+			"RequestURL":      uploadURL,
+			"Headers":         gin.H{"X-API-Key": plain},
+			"Body":            "MultipartFormData",
+			"FileFormName":    "file",
+			"URL":             "{json:url}",
+			"ThumbnailURL":    "{json:thumbnail_url}",
+			"DeletionURL":     "{json:deletion_url}",
+			"ErrorMessage":    "{json:error}",
+		}
+	} else {
+		// PicGo（Typora 通过 PicGo-Core 上传）使用 web-uploader 插件
+		filename = "picgo-config.json"
+		header, _ := json.Marshal(gin.H{"X-API-Key": plain})
+		body = gin.H{
+			"picBed": gin.H{
+				"uploader": "web-uploader",
+				"current":  "web-uploader",
+				"web-uploader": gin.H{
+					"url":          uploadURL,
+					"paramName":    "file",
+					"jsonPath":     "url",
+					"customHeader": string(header),
+					"customBody":   "",
+				},
+			},
+			"picgoPlugins": gin.H{"picgo-plugin-web-uploader": true},
+		}
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.IndentedJSON(http.StatusOK, body)
+}
+
+// publicBaseURL 对外访问地址：优先 PUBLIC_BASE_URL，否则由请求头推导
+func publicBaseURL(c *gin.Context) string {
+	if config.AppConfig.PublicBaseURL != "" {
+		return config.AppConfig.PublicBaseURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// absoluteURL 将 /uploads/... 等相对地址补全为绝对地址
+func absoluteURL(base, u string) string {
+	if u == "" || strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+		return u
+	}
+	return base + u
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"image-host/database"
+	"image-host/models"
+	"image-host/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExportController struct{}
+
+var Export = &ExportController{}
+
+// Zip 以 ZIP 流导出图片与 manifest.json，过滤参数与列表/搜索相同（如 album=ID 导出相册）；
+// 非 admin（含游客）仅导出自己上传的图片
+// GET /api/v1/images/export
+func (ec *ExportController) Zip(c *gin.Context) {
+	filter, err := services.ParseImageFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "INVALID_FILTER"})
+		return
+	}
+	if !Upload.scopeFilter(c, filter) {
+		return
+	}
+
+	var images []models.Image
+	if err := filter.Apply(database.DB).Order(filter.Order()).Find(&images).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images", "code": "DATABASE_ERROR"})
+		return
+	}
+	services.Tags.Fill(images)
+
+	name := "images-" + time.Now().Format("20060102-150405") + ".zip"
+	if filter.AlbumID != 0 {
+		var album models.Album
+		if database.DB.First(&album, filter.AlbumID).Error == nil {
+			name = album.Name + ".zip"
+		}
+	}
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Accel-Buffering", "no") // 经 nginx 代理时不缓冲，边读边发
+	c.Status(http.StatusOK)
+
+	// 响应头已发送，出错时只能中断输出
+	if err := services.Export.WriteZip(c.Writer, images, c.GetString("username")); err != nil {
+		log.Printf("Export aborted: %v", err)
+	}
+}
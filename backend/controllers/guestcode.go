@@ -2,9 +2,9 @@ package controllers
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
-	"image-host/config"
 	"image-host/database"
 	"image-host/models"
 	"image-host/services"
@@ -16,24 +16,105 @@ type GuestCodeController struct{}
 
 var GuestCode = &GuestCodeController{}
 
-// Create 生成游客码
-// 请求: { days?: number, expires_at?: number(unix秒), permanent?: boolean }
+// guestCodeRequest 游客码的标签与使用限制，未提供的字段保持不变
+type guestCodeRequest struct {
+	Label        *string `json:"label"`
+	MaxLogins    *int    `json:"max_logins"`
+	MaxUploads   *int    `json:"max_uploads"`
+	AllowedTypes *string `json:"allowed_types"`
+	MaxFileSize  *int64  `json:"max_file_size"`
+	Disabled     *bool   `json:"disabled"`
+}
+
+// normalize 校验取值并规范化标签与 MIME 类型列表，返回错误信息
+func (r *guestCodeRequest) normalize() string {
+	if r.Label != nil {
+		label := strings.TrimSpace(*r.Label)
+		if len(label) > 64 {
+			return "Label too long"
+		}
+		r.Label = &label
+	}
+	if (r.MaxLogins != nil && *r.MaxLogins < 0) || (r.MaxUploads != nil && *r.MaxUploads < 0) {
+		return "Limits must not be negative"
+	}
+	if r.MaxFileSize != nil && *r.MaxFileSize < 0 {
+		return "Max file size must not be negative"
+	}
+	if r.AllowedTypes != nil {
+		types, err := services.NormalizeAllowedTypes(*r.AllowedTypes)
+		if err != nil {
+			return "Allowed types must be a subset of ALLOWED_TYPES"
+		}
+		r.AllowedTypes = &types
+	}
+	return ""
+}
+
+func (r guestCodeRequest) options() services.GuestCodeOptions {
+	var opts services.GuestCodeOptions
+	if r.Label != nil {
+		opts.Label = *r.Label
+	}
+	if r.MaxLogins != nil {
+		opts.MaxLogins = *r.MaxLogins
+	}
+	if r.MaxUploads != nil {
+		opts.MaxUploads = *r.MaxUploads
+	}
+	if r.AllowedTypes != nil {
+		opts.AllowedTypes = *r.AllowedTypes
+	}
+	if r.MaxFileSize != nil {
+		opts.MaxFileSize = *r.MaxFileSize
+	}
+	return opts
+}
+
+func (r guestCodeRequest) updates() map[string]interface{} {
+	updates := map[string]interface{}{}
+	if r.Label != nil {
+		updates["label"] = *r.Label
+	}
+	if r.MaxLogins != nil {
+		updates["max_logins"] = *r.MaxLogins
+	}
+	if r.MaxUploads != nil {
+		updates["max_uploads"] = *r.MaxUploads
+	}
+	if r.AllowedTypes != nil {
+		updates["allowed_types"] = *r.AllowedTypes
+	}
+	if r.MaxFileSize != nil {
+		updates["max_file_size"] = *r.MaxFileSize
+	}
+	if r.Disabled != nil {
+		updates["disabled"] = *r.Disabled
+	}
+	return updates
+}
+
+// Create 生成游客码（路由限定管理员）
+// 请求: { days?: number, expires_at?: number(unix秒), permanent?: boolean, label?, max_logins?, max_uploads?,
+// allowed_types?: "image/png,image/jpeg", max_file_size?, quota_max_bytes?, quota_max_images?, quota_max_daily? }
 func (g *GuestCodeController) Create(c *gin.Context) {
 	creator := c.GetString("username")
-	if creator != config.AppConfig.DefaultAdmin {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
-		return
-	}
 
 	var payload struct {
 		Days      *int   `json:"days"`
 		ExpiresAt *int64 `json:"expires_at"`
 		Permanent bool   `json:"permanent"`
+		guestCodeRequest
+		quotaRequest
 	}
-	if err := c.ShouldBindJSON(&payload); err != nil {
+	if err := c.ShouldBindJSON(&payload); err != nil || !payload.quotaRequest.valid() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
 		return
 	}
+	if msg := payload.guestCodeRequest.normalize(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "code": "INVALID_PAYLOAD"})
+		return
+	}
 
 	var expPtr *time.Time
 	if payload.Permanent {
@@ -50,7 +131,7 @@ func (g *GuestCodeController) Create(c *gin.Context) {
 		expPtr = &t
 	}
 
-	code, err := services.Guest.GenerateCode(creator, expPtr)
+	code, err := services.Guest.GenerateCode(creator, expPtr, payload.guestCodeRequest.options(), payload.quotaRequest.limits())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate code"})
 		return
@@ -58,28 +139,72 @@ func (g *GuestCodeController) Create(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": code})
 }
 
-// List 列出游客码
-func (g *GuestCodeController) List(c *gin.Context) {
-	creator := c.GetString("username")
-	if creator != config.AppConfig.DefaultAdmin {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+// Update 修改游客码的标签、使用限制、停用状态与配额；停用不删除已上传的图片
+// PUT /api/v1/guest-codes/:id  { label?, max_logins?, max_uploads?, allowed_types?, max_file_size?, disabled?,
+// quota_max_bytes?, quota_max_images?, quota_max_daily? }（配额 -1 恢复默认，0 不限）
+func (g *GuestCodeController) Update(c *gin.Context) {
+	var gc models.GuestCode
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&gc).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Guest code not found", "code": "NOT_FOUND"})
+		return
+	}
+	var req struct {
+		guestCodeRequest
+		quotaRequest
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || !req.quotaRequest.valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "code": "INVALID_PAYLOAD"})
+		return
+	}
+	if msg := req.guestCodeRequest.normalize(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "code": "INVALID_PAYLOAD"})
 		return
 	}
+	updates := req.guestCodeRequest.updates()
+	for k, v := range req.quotaRequest.updates() {
+		updates[k] = v
+	}
+	if len(updates) > 0 {
+		if err := database.DB.Model(&gc).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update guest code", "code": "DATABASE_ERROR"})
+			return
+		}
+		database.DB.First(&gc, gc.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gc})
+}
+
+// guestCodeItem 游客码及其用量：upload_count 为累计上传次数，usage 为现存图片的数量与字节数
+type guestCodeItem struct {
+	models.GuestCode
+	Usage services.GuestCodeUsage `json:"usage"`
+}
+
+// List 列出游客码及用量统计
+func (g *GuestCodeController) List(c *gin.Context) {
 	var list []models.GuestCode
 	if err := database.DB.Order("id DESC").Find(&list).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+	ids := make([]uint, 0, len(list))
+	for _, gc := range list {
+		ids = append(ids, gc.ID)
+	}
+	usage, err := services.Guest.Usage(ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch usage", "code": "DATABASE_ERROR"})
+		return
+	}
+	items := make([]guestCodeItem, 0, len(list))
+	for _, gc := range list {
+		items = append(items, guestCodeItem{GuestCode: gc, Usage: usage[gc.ID]})
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": items})
 }
 
 // Delete 删除游客码并清理其图片
 func (g *GuestCodeController) Delete(c *gin.Context) {
-	creator := c.GetString("username")
-	if creator != config.AppConfig.DefaultAdmin {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
-		return
-	}
 	id := c.Param("id")
 	if err := services.Guest.DeleteCodeAndImages(id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"image-host/database"
+	"image-host/models"
+	"image-host/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HotlinkController struct{}
+
+var Hotlink = &HotlinkController{}
+
+type hotlinkRequest struct {
+	Policy  *string   `json:"policy"`  // "" 继承 / on / off
+	Domains *[]string `json:"domains"` // 额外允许的来源域名
+}
+
+// hotlinkUpdates 将请求转换为字段更新，失败时已写入响应
+func hotlinkUpdates(c *gin.Context, req hotlinkRequest) (map[string]interface{}, bool) {
+	updates := map[string]interface{}{}
+	if req.Policy != nil {
+		if !models.ValidHotlinkPolicy(*req.Policy) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hotlink policy", "code": "INVALID_HOTLINK_POLICY"})
+			return nil, false
+		}
+		updates["hotlink_policy"] = *req.Policy
+	}
+	if req.Domains != nil {
+		domains := services.NormalizeDomains(*req.Domains)
+		if len(domains) > 512 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Too many domains", "code": "INVALID_PAYLOAD"})
+			return nil, false
+		}
+		updates["hotlink_domains"] = domains
+	}
+	return updates, true
+}
+
+// SetImage 设置单张图片的防盗链覆盖
+// PUT /api/v1/images/:uuid/hotlink  { policy?: ""|"on"|"off", domains?: [] }
+func (hc *HotlinkController) SetImage(c *gin.Context) {
+	image, ok := ownedImage(c)
+	if !ok {
+		return
+	}
+	var req hotlinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "code": "INVALID_PAYLOAD"})
+		return
+	}
+	updates, ok := hotlinkUpdates(c, req)
+	if !ok {
+		return
+	}
+	if len(updates) > 0 {
+		if err := database.DB.Model(image).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update image", "code": "DATABASE_ERROR"})
+			return
+		}
+		database.DB.First(image, image.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"uuid":            image.UUID,
+			"hotlink_policy":  image.HotlinkPolicy,
+			"hotlink_domains": image.HotlinkDomains,
+		},
+	})
+}
+
+// Stats 被拦截的盗链请求统计（仅管理员）
+// GET /api/v1/hotlink/stats?days=7&limit=20
+func (hc *HotlinkController) Stats(c *gin.Context) {
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "7"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if days < 1 || days > 365 {
+		days = 7
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	// 先写入内存中尚未落库的计数
+	_ = services.Hotlink.Flush()
+	referers, images, err := services.Hotlink.Stats(days, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch hotlink stats", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"days":         days,
+			"top_referers": referers,
+			"top_images":   images,
+		},
+	})
+}
@@ -0,0 +1,309 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"image-host/config"
+	"image-host/models"
+	"image-host/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImportController struct{}
+
+var Import = &ImportController{}
+
+// 目录映射方式
+const (
+	importFoldersNone  = "none"
+	importFoldersAlbum = "album" // 每个目录（完整相对路径）对应一个同名相册
+	importFoldersTag   = "tag"   // 目录的每一级作为标签
+)
+
+// importResult 单个归档条目的导入结果，status 为 imported / failed / skipped
+type importResult struct {
+	Index  int      `json:"index"`
+	Name   string   `json:"name"`
+	Status string   `json:"status"`
+	Code   string   `json:"code,omitempty"`
+	Error  string   `json:"error,omitempty"`
+	UUID   string   `json:"uuid,omitempty"`
+	Album  string   `json:"album,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+
+	dir     string
+	imageID uint
+}
+
+// Archive 导入 ZIP 或 tar.gz 归档中的全部图片，逐个条目返回结果
+// POST /api/v1/images/import  multipart: archive, folders?=none|album|tag, tags?, visibility?
+func (ic *ImportController) Archive(c *gin.Context) {
+	file, header, err := c.Request.FormFile("archive")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to get uploaded archive", "code": "INVALID_FILE"})
+		return
+	}
+	defer file.Close()
+
+	folders := c.DefaultPostForm("folders", importFoldersNone)
+	if folders != importFoldersNone && folders != importFoldersAlbum && folders != importFoldersTag {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folders mode", "code": "INVALID_PAYLOAD"})
+		return
+	}
+	tags, err := services.NormalizeTags(c.PostFormArray("tags"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "INVALID_TAG"})
+		return
+	}
+	visibility := c.DefaultPostForm("visibility", models.VisibilityPublic)
+	if !models.ValidVisibility(visibility) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid visibility", "code": "INVALID_VISIBILITY"})
+		return
+	}
+
+	owner := ownerOf(c)
+	// 配额在写入记录时加锁校验，并发处理不会超额
+	workers := config.AppConfig.ImportConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	// 顺序读取归档，条目交给 worker 并发处理；读取端在 worker 繁忙时阻塞，内存占用有上限
+	var (
+		results []*importResult
+		wg      sync.WaitGroup
+	)
+	jobs := make(chan func())
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				job()
+			}
+		}()
+	}
+
+	limits := services.ArchiveLimits{
+		MaxEntries:   config.AppConfig.ImportMaxEntries,
+		MaxEntrySize: config.AppConfig.MaxFileSize,
+		MaxTotalSize: config.AppConfig.ImportMaxTotalSize,
+	}
+	walkErr := services.WalkArchive(file, header.Size, limits, func(entry *services.ArchiveEntry) {
+		r := &importResult{Index: entry.Index, Name: entry.Name, dir: entry.Dir}
+		results = append(results, r)
+
+		switch {
+		case entry.Err != nil:
+			r.Status, r.Code, r.Error = "failed", "INVALID_ENTRY", entry.Err.Error()
+			return
+		case entry.Skipped:
+			r.Status = "skipped"
+			return
+		}
+
+		entryTags := tags
+		if folders == importFoldersTag {
+			entryTags = mergeTags(tags, folderTags(entry.Dir))
+		}
+		data := entry.Data
+		jobs <- func() {
+			image, uerr := Upload.ingest(owner, uploadInput{
+				filename:   path.Base(entry.Name),
+				data:       data,
+				tags:       entryTags,
+				visibility: visibility,
+			})
+			if uerr != nil {
+				r.Status, r.Code, r.Error = "failed", uerr.code, uerr.message
+				return
+			}
+			r.Status, r.UUID, r.Tags, r.imageID = "imported", image.UUID, image.Tags, image.ID
+		}
+	})
+	close(jobs)
+	wg.Wait()
+
+	if walkErr != nil && len(results) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": walkErr.Error(), "code": "INVALID_ARCHIVE"})
+		return
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Index < results[j].Index })
+	if folders == importFoldersAlbum {
+		ic.assignAlbums(owner.username, results)
+	}
+
+	counts := map[string]int{"imported": 0, "failed": 0, "skipped": 0}
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	data := gin.H{
+		"total":    len(results),
+		"imported": counts["imported"],
+		"failed":   counts["failed"],
+		"skipped":  counts["skipped"],
+		"entries":  results,
+	}
+	// 超出条目数或解压总量限制时中止，已处理的条目保留
+	if walkErr != nil {
+		code := "INVALID_ARCHIVE"
+		if errors.Is(walkErr, services.ErrArchiveTooManyEntries) || errors.Is(walkErr, services.ErrArchiveTooLarge) {
+			code = "ARCHIVE_LIMIT_EXCEEDED"
+		}
+		data["aborted"] = gin.H{"error": walkErr.Error(), "code": code}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
+}
+
+// importURLRequest 远程地址导入
+type importURLRequest struct {
+	URL        string   `json:"url"`
+	Tags       []string `json:"tags"`
+	Visibility string   `json:"visibility"`
+}
+
+// URL 下载远程图片后按单图上传流程校验、处理并保存
+// POST /api/v1/images/import-url  { url, tags?, visibility? }
+func (ic *ImportController) URL(c *gin.Context) {
+	var req importURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.URL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "code": "INVALID_PAYLOAD"})
+		return
+	}
+	tags, err := services.NormalizeTags(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "INVALID_TAG"})
+		return
+	}
+	if req.Visibility == "" {
+		req.Visibility = models.VisibilityPublic
+	}
+	if !models.ValidVisibility(req.Visibility) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid visibility", "code": "INVALID_VISIBILITY"})
+		return
+	}
+
+	remote, err := services.RemoteFetch.Fetch(c.Request.Context(), req.URL, config.AppConfig.MaxFileSize)
+	if err != nil {
+		status, code, message := remoteFetchError(err)
+		c.JSON(status, gin.H{"error": message, "code": code})
+		return
+	}
+
+	image, uerr := Upload.ingest(ownerOf(c), uploadInput{
+		filename:    remote.FileName,
+		contentType: remote.ContentType,
+		data:        remote.Data,
+		tags:        tags,
+		visibility:  req.Visibility,
+	})
+	if uerr != nil {
+		c.JSON(uerr.status, gin.H{"error": uerr.message, "code": uerr.code})
+		return
+	}
+	result := imageResult(image)
+	result["source_url"] = remote.FinalURL
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": result})
+}
+
+// remoteFetchError 下载失败原因对应的状态码、错误码与提示。提示为固定文本，
+// 不回显底层错误，以免泄露解析得到的内网地址等信息
+func remoteFetchError(err error) (int, string, string) {
+	var se *services.RemoteStatusError
+	switch {
+	case errors.Is(err, services.ErrRemoteURLInvalid):
+		return http.StatusBadRequest, "INVALID_URL", "Invalid URL, only http and https are supported"
+	case errors.Is(err, services.ErrRemoteAddressBlocked):
+		return http.StatusBadRequest, "REMOTE_ADDRESS_BLOCKED", "Remote address is not allowed"
+	case errors.Is(err, services.ErrRemoteTooManyHops):
+		return http.StatusBadRequest, "TOO_MANY_REDIRECTS", "Too many redirects"
+	case errors.Is(err, services.ErrRemoteTooLarge):
+		return http.StatusBadRequest, "REMOTE_TOO_LARGE", "Remote file exceeds size limit"
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "REMOTE_TIMEOUT", "Timed out fetching remote file"
+	case errors.As(err, &se):
+		return http.StatusBadGateway, "REMOTE_STATUS", se.Error()
+	}
+	return http.StatusBadGateway, "REMOTE_FETCH_FAILED", "Failed to fetch remote file"
+}
+
+// assignAlbums 按目录将导入成功的图片加入同名相册（不存在时创建），相册内保持归档顺序
+func (ic *ImportController) assignAlbums(owner string, results []*importResult) {
+	byDir := map[string][]*importResult{}
+	var dirs []string
+	for _, r := range results {
+		if r.Status != "imported" || r.dir == "" {
+			continue
+		}
+		if _, ok := byDir[r.dir]; !ok {
+			dirs = append(dirs, r.dir)
+		}
+		byDir[r.dir] = append(byDir[r.dir], r)
+	}
+	for _, dir := range dirs {
+		name := truncateRunes(dir, 128)
+		album, err := services.Albums.FindOrCreate(owner, name)
+		if err != nil {
+			continue
+		}
+		ids := make([]uint, 0, len(byDir[dir]))
+		for _, r := range byDir[dir] {
+			ids = append(ids, r.imageID)
+		}
+		if _, err := services.Albums.AddImages(album.ID, ids); err != nil {
+			continue
+		}
+		for _, r := range byDir[dir] {
+			r.Album = name
+		}
+	}
+}
+
+// folderTags 将目录的每一级转为标签（逗号替换为空格，超长截断）
+func folderTags(dir string) []string {
+	if dir == "" {
+		return nil
+	}
+	var out []string
+	for _, seg := range strings.Split(dir, "/") {
+		seg = truncateRunes(strings.TrimSpace(strings.ReplaceAll(seg, ",", " ")), 64)
+		if seg != "" {
+			out = append(out, seg)
+		}
+	}
+	return out
+}
+
+// mergeTags 合并并规范化标签，超出单图上限时截断
+func mergeTags(base, extra []string) []string {
+	merged, err := services.NormalizeTags(append(append([]string{}, base...), extra...))
+	if err == nil {
+		return merged
+	}
+	seen := map[string]bool{}
+	out := []string{}
+	for _, t := range append(append([]string{}, base...), extra...) {
+		t = strings.ToLower(t)
+		if !seen[t] && len(out) < services.MaxTagsPerImage {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
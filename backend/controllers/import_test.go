@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"image-host/services"
+)
+
+func TestRemoteFetchError(t *testing.T) {
+	// 与 http.Client 返回的错误形式一致：*url.Error 包裹底层错误
+	wrap := func(err error) error {
+		return &url.Error{Op: "Get", URL: "http://10.0.0.1/a.png", Err: fmt.Errorf("dial tcp 10.0.0.1:80: %w", err)}
+	}
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{services.ErrRemoteURLInvalid, http.StatusBadRequest, "INVALID_URL"},
+		{wrap(services.ErrRemoteAddressBlocked), http.StatusBadRequest, "REMOTE_ADDRESS_BLOCKED"},
+		{wrap(services.ErrRemoteTooManyHops), http.StatusBadRequest, "TOO_MANY_REDIRECTS"},
+		{services.ErrRemoteTooLarge, http.StatusBadRequest, "REMOTE_TOO_LARGE"},
+		{wrap(context.DeadlineExceeded), http.StatusGatewayTimeout, "REMOTE_TIMEOUT"},
+		{&services.RemoteStatusError{StatusCode: 404}, http.StatusBadGateway, "REMOTE_STATUS"},
+		{wrap(errors.New("connection refused")), http.StatusBadGateway, "REMOTE_FETCH_FAILED"},
+	}
+	for _, tc := range cases {
+		status, code, message := remoteFetchError(tc.err)
+		if status != tc.status || code != tc.code {
+			t.Errorf("remoteFetchError(%v) = %d %s, want %d %s", tc.err, status, code, tc.status, tc.code)
+		}
+		// 返回给客户端的提示不能包含底层错误中的地址
+		if strings.Contains(message, "10.0.0.1") || message == "" {
+			t.Errorf("remoteFetchError(%v) message = %q", tc.err, message)
+		}
+	}
+}
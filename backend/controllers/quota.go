@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"image-host/models"
+)
+
+// quotaRequest 配额设置：-1 恢复为全局默认，0 表示不限，正数为上限
+type quotaRequest struct {
+	QuotaMaxBytes  *int64 `json:"quota_max_bytes"`
+	QuotaMaxImages *int64 `json:"quota_max_images"`
+	QuotaMaxDaily  *int64 `json:"quota_max_daily"`
+}
+
+// valid 是否均为合法取值
+func (r quotaRequest) valid() bool {
+	for _, v := range []*int64{r.QuotaMaxBytes, r.QuotaMaxImages, r.QuotaMaxDaily} {
+		if v != nil && *v < -1 {
+			return false
+		}
+	}
+	return true
+}
+
+// updates 转换为字段更新，-1 写入 NULL
+func (r quotaRequest) updates() map[string]interface{} {
+	updates := map[string]interface{}{}
+	set := func(column string, v *int64) {
+		if v == nil {
+			return
+		}
+		if *v == -1 {
+			updates[column] = nil
+			return
+		}
+		updates[column] = *v
+	}
+	set("quota_max_bytes", r.QuotaMaxBytes)
+	set("quota_max_images", r.QuotaMaxImages)
+	set("quota_max_daily", r.QuotaMaxDaily)
+	return updates
+}
+
+// limits 转换为新建记录时的配额覆盖
+func (r quotaRequest) limits() models.QuotaLimits {
+	pick := func(v *int64) *int64 {
+		if v == nil || *v == -1 {
+			return nil
+		}
+		return v
+	}
+	return models.QuotaLimits{
+		QuotaMaxBytes:  pick(r.QuotaMaxBytes),
+		QuotaMaxImages: pick(r.QuotaMaxImages),
+		QuotaMaxDaily:  pick(r.QuotaMaxDaily),
+	}
+}
@@ -0,0 +1,348 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"image-host/config"
+	"image-host/database"
+	"image-host/models"
+	"image-host/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ServeController struct{}
+
+var Serve = &ServeController{}
+
+// Image 按 UUID 输出图片，支持预设变换；私有图片返回 404（需通过分享链接或签名 URL 访问）
+// GET /img/:uuid?preset=thumb 或 /img/:uuid?w=800&h=600&fit=cover&fmt=webp&q=80（需与某个预设一致）
+// 签名 URL：/img/:uuid?exp=...&sig=...，签名有效时可访问私有图片
+func (sc *ServeController) Image(c *gin.Context) {
+	uuid := c.Param("uuid")
+	signed := c.Query("sig") != ""
+	if signed {
+		if err := services.VerifyImageSignature(uuid, c.Request.URL.Query()); err != nil {
+			if errors.Is(err, services.ErrSignatureExpired) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error": "Signed URL has expired",
+					"code":  "SIGNATURE_EXPIRED",
+				})
+				return
+			}
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Invalid signature",
+				"code":  "INVALID_SIGNATURE",
+			})
+			return
+		}
+	}
+
+	var image models.Image
+	q := database.DB.Where("uuid = ?", uuid)
+	if !signed {
+		q = q.Where("visibility <> ?", models.VisibilityPrivate)
+	}
+	if err := q.First(&image).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Image not found",
+			"code":  "NOT_FOUND",
+		})
+		return
+	}
+
+	opts, ok := sc.transformOptions(c)
+	if !ok {
+		return
+	}
+
+	// 签名 URL 为显式授权，不做来源校验
+	if !signed && !sc.checkHotlink(c, &image) {
+		return
+	}
+	defer sc.recordTraffic(c, &image)
+
+	if signed {
+		// 签名 URL 仅在有效期内缓存
+		exp, _ := strconv.ParseInt(c.Query("exp"), 10, 64)
+		c.Header("Cache-Control", "private, max-age="+strconv.FormatInt(exp-time.Now().Unix(), 10))
+	} else {
+		// 图片按 UUID 不可变，允许长期缓存
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	sc.stream(c, &image, opts)
+}
+
+// Share 通过分享链接输出图片，校验过期时间、访问次数与密码后计入一次访问
+// GET|POST /s/:slug，密码通过 X-Share-Password 头或 POST 表单字段 password 提供（不接受查询参数，
+// 以免出现在访问日志、浏览器历史与 Referer 中），支持与 /img 相同的变换参数
+func (sc *ServeController) Share(c *gin.Context) {
+	opts, ok := sc.transformOptions(c)
+	if !ok {
+		return
+	}
+
+	password := c.GetHeader("X-Share-Password")
+	if password == "" && c.Request.Method == http.MethodPost {
+		password = c.PostForm("password")
+	}
+
+	_, image, err := services.Shares.Open(c.Param("slug"), password, c.ClientIP(), shareCountsView(c.Request))
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrShareNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found", "code": "NOT_FOUND"})
+		return
+	case errors.Is(err, services.ErrShareExpired):
+		c.JSON(http.StatusGone, gin.H{"error": "Share link has expired", "code": "SHARE_EXPIRED"})
+		return
+	case errors.Is(err, services.ErrSharePasswordRequired):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password required", "code": "PASSWORD_REQUIRED"})
+		return
+	case errors.Is(err, services.ErrSharePasswordInvalid):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid password", "code": "INVALID_PASSWORD"})
+		return
+	case errors.Is(err, services.ErrShareLocked):
+		var locked *services.ShareLockedError
+		retryAfter := 60
+		if errors.As(err, &locked) {
+			retryAfter = int(locked.RetryAfter.Seconds()) + 1
+		}
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "Too many failed password attempts",
+			"code":        "TOO_MANY_ATTEMPTS",
+			"retry_after": retryAfter,
+		})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open share link", "code": "DATABASE_ERROR"})
+		return
+	}
+
+	defer sc.recordTraffic(c, image)
+
+	// 每次访问都需经过校验与计数，禁止缓存
+	c.Header("Cache-Control", "private, no-store")
+	sc.stream(c, image, opts)
+}
+
+// shareCountsView 请求是否计为分享链接的一次访问：HEAD 不计；分段下载只在从首字节开始的请求计一次，
+// 后续分段不计（但访问次数用尽后同样被拒绝）
+func shareCountsView(r *http.Request) bool {
+	if r.Method == http.MethodHead {
+		return false
+	}
+	rng := strings.TrimSpace(r.Header.Get("Range"))
+	return rng == "" || strings.HasPrefix(rng, "bytes=0-")
+}
+
+// Uploads 按存储 key 输出原图或缩略图（替代原先的静态目录映射），私有图片不对外提供
+// GET /uploads/*key
+func (sc *ServeController) Uploads(c *gin.Context) {
+	key := strings.TrimPrefix(path.Clean("/"+c.Param("filepath")), "/")
+	image, thumb, err := services.Library.FindByFileKey(key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Image not found",
+			"code":  "NOT_FOUND",
+		})
+		return
+	}
+	if !sc.checkHotlink(c, image) {
+		return
+	}
+	defer sc.recordTraffic(c, image)
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	if thumb {
+		sc.serveObject(c, image, key, mime.TypeByExtension(path.Ext(key)), imageETag(image, "thumb"))
+		return
+	}
+	sc.serveObject(c, image, key, image.MimeType, imageETag(image, ""))
+}
+
+// recordTraffic 异步记录访问量与流量：完整输出（200）计一次访问，Range 续传（206）只计流量
+func (sc *ServeController) recordTraffic(c *gin.Context, image *models.Image) {
+	if c.Request.Method == http.MethodHead {
+		return
+	}
+	size := int64(c.Writer.Size())
+	if size < 0 {
+		size = 0
+	}
+	switch c.Writer.Status() {
+	case http.StatusOK:
+		services.Traffic.Record(image.ID, services.RefererHost(c.GetHeader("Referer")), size, true)
+	case http.StatusPartialContent:
+		services.Traffic.Record(image.ID, services.RefererHost(c.GetHeader("Referer")), size, false)
+	}
+}
+
+// checkHotlink 防盗链校验，拦截时记录来源并返回占位图或 403（已写入响应）
+func (sc *ServeController) checkHotlink(c *gin.Context, image *models.Image) bool {
+	allowed, enforced, refererHost := services.Hotlink.Check(image, c.GetHeader("Referer"), c.Request.Host)
+	if enforced {
+		c.Header("Vary", "Referer")
+	}
+	if allowed {
+		return true
+	}
+
+	services.Hotlink.Record(image.ID, refererHost)
+	if placeholder := config.AppConfig.HotlinkPlaceholder; placeholder != "" {
+		c.Header("Cache-Control", "no-store")
+		c.File(placeholder)
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "Hotlinking is not allowed",
+		"code":  "HOTLINK_FORBIDDEN",
+	})
+	return false
+}
+
+// transformOptions 解析变换参数，失败时已写入响应
+func (sc *ServeController) transformOptions(c *gin.Context) (services.TransformOptions, bool) {
+	opts, err := services.Transform.Resolve(c.Request.URL.Query())
+	if err != nil {
+		if errors.Is(err, services.ErrTransformNotAllowed) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Transform parameters are not in the preset allow-list",
+				"code":  "TRANSFORM_NOT_ALLOWED",
+			})
+			return opts, false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+			"code":  "INVALID_TRANSFORM",
+		})
+		return opts, false
+	}
+	return opts, true
+}
+
+// stream 输出原图或变换结果
+func (sc *ServeController) stream(c *gin.Context, image *models.Image, opts services.TransformOptions) {
+	if opts.IsZero() {
+		sc.serveObject(c, image, image.R2Key, image.MimeType, imageETag(image, ""))
+		return
+	}
+
+	etag := imageETag(image, services.VariantTag(opts))
+	if notModified(c, etag) {
+		return
+	}
+	data, mimeType, err := services.Transform.Variant(image.R2Key, opts)
+	if err != nil {
+		serveStorageError(c, err)
+		return
+	}
+	serveContent(c, image, bytes.NewReader(data), mimeType, etag)
+}
+
+// serveObject 从存储后端输出文件；命中 If-None-Match 时不读取存储
+func (sc *ServeController) serveObject(c *gin.Context, image *models.Image, key, mimeType, etag string) {
+	if notModified(c, etag) {
+		return
+	}
+	// Range 需要可 Seek 的内容：本地文件直接使用，S3 按请求的区间下载
+	rs, err := services.R2.OpenSeeker(key)
+	if err != nil {
+		serveStorageError(c, err)
+		return
+	}
+	defer rs.Close()
+	serveContent(c, image, rs, mimeType, etag)
+}
+
+// serveContent 设置 ETag / Content-Type / Content-Disposition 后交给 http.ServeContent，
+// 由其处理 Range、If-Range、If-None-Match 与 If-Modified-Since
+func serveContent(c *gin.Context, image *models.Image, content io.ReadSeeker, mimeType, etag string) {
+	h := c.Writer.Header()
+	h.Set("ETag", etag)
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	h.Set("Content-Type", mimeType)
+	if c.Query("download") == "1" {
+		name := image.OriginalName
+		if name == "" {
+			name = image.UUID
+		}
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": name})
+		if disposition == "" {
+			disposition = "attachment"
+		}
+		h.Set("Content-Disposition", disposition)
+	}
+	// 存储对象不可变，以记录创建时间作为 Last-Modified
+	http.ServeContent(c.Writer, c.Request, "", image.CreatedAt, content)
+}
+
+// notModified 在读取存储前处理 If-None-Match，命中时直接返回 304
+func notModified(c *gin.Context, etag string) bool {
+	inm := c.GetHeader("If-None-Match")
+	if inm == "" {
+		return false
+	}
+	for _, tag := range strings.Split(inm, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			c.Header("ETag", etag)
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// imageETag 基于内容哈希的强 ETag，variant 区分缩略图与变换结果；
+// 旧记录无内容哈希时以 UUID 代替（同一 UUID 内容不变）
+func imageETag(image *models.Image, variant string) string {
+	tag := image.ContentHash
+	if tag == "" {
+		tag = image.UUID
+	}
+	if variant != "" {
+		tag += "-" + variant
+	}
+	return `"` + tag + `"`
+}
+
+// Presets 列出可用的变换预设
+// GET /api/v1/images/presets
+func (sc *ServeController) Presets(c *gin.Context) {
+	presets := gin.H{}
+	for name, o := range services.Transform.Presets() {
+		presets[name] = gin.H{
+			"w":   o.Width,
+			"h":   o.Height,
+			"fit": o.Fit,
+			"fmt": o.Format,
+			"q":   o.Quality,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": presets})
+}
+
+func serveStorageError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrObjectNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Image file not found",
+			"code":  "NOT_FOUND",
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": "Failed to load image",
+		"code":  "STORAGE_ERROR",
+	})
+}
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestShareCountsView(t *testing.T) {
+	cases := []struct {
+		method string
+		rng    string
+		want   bool
+	}{
+		{http.MethodGet, "", true},
+		{http.MethodPost, "", true},
+		{http.MethodHead, "", false},
+		{http.MethodHead, "bytes=0-", false},
+		// 从首字节开始的分段计一次，后续分段不计
+		{http.MethodGet, "bytes=0-", true},
+		{http.MethodGet, " bytes=0-99", true},
+		{http.MethodGet, "bytes=100-", false},
+		{http.MethodGet, "bytes=100-199", false},
+		{http.MethodGet, "bytes=-500", false},
+	}
+	for _, tc := range cases {
+		r, _ := http.NewRequest(tc.method, "/s/abc", nil)
+		if tc.rng != "" {
+			r.Header.Set("Range", tc.rng)
+		}
+		if got := shareCountsView(r); got != tc.want {
+			t.Errorf("%s Range %q: counts = %v, want %v", tc.method, tc.rng, got, tc.want)
+		}
+	}
+}
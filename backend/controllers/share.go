@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"image-host/config"
+	"image-host/database"
+	"image-host/models"
+	"image-host/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ShareController struct{}
+
+var Share = &ShareController{}
+
+type shareRequest struct {
+	Password  string `json:"password"`
+	ExpiresAt *int64 `json:"expires_at"` // unix 秒
+	Days      *int   `json:"days"`
+	MaxViews  int    `json:"max_views"` // 0 表示不限
+}
+
+type signedURLRequest struct {
+	TTL    int    `json:"ttl"` // 秒，默认 3600
+	Preset string `json:"preset"`
+	W      int    `json:"w"`
+	H      int    `json:"h"`
+	Fit    string `json:"fit"`
+	Fmt    string `json:"fmt"`
+	Q      int    `json:"q"`
+}
+
+type visibilityRequest struct {
+	Visibility string `json:"visibility"`
+}
+
+// List 列出图片的分享链接
+// GET /api/v1/images/:uuid/shares
+func (sc *ShareController) List(c *gin.Context) {
+	image, ok := ownedImage(c)
+	if !ok {
+		return
+	}
+	var links []models.ShareLink
+	if err := database.DB.Where("image_id = ?", image.ID).Order("id DESC").Find(&links).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch share links", "code": "DATABASE_ERROR"})
+		return
+	}
+	services.Shares.Fill(links)
+	base := publicBaseURL(c)
+	data := make([]gin.H, 0, len(links))
+	for i := range links {
+		data = append(data, shareResult(base, &links[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
+}
+
+// Create 创建分享链接
+// POST /api/v1/images/:uuid/shares  { password?, days?, expires_at?, max_views? }
+func (sc *ShareController) Create(c *gin.Context) {
+	image, ok := ownedImage(c)
+	if !ok {
+		return
+	}
+	var req shareRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.MaxViews < 0 || len(req.Password) > 128 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "code": "INVALID_PAYLOAD"})
+		return
+	}
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil && *req.ExpiresAt > 0 {
+		t := time.Unix(*req.ExpiresAt, 0)
+		expiresAt = &t
+	} else if req.Days != nil && *req.Days > 0 {
+		t := time.Now().Add(time.Duration(*req.Days) * 24 * time.Hour)
+		expiresAt = &t
+	}
+
+	link, err := services.Shares.Create(image, c.GetString("username"), strings.TrimSpace(req.Password), expiresAt, req.MaxViews)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": shareResult(publicBaseURL(c), link)})
+}
+
+// Delete 撤销分享链接
+// DELETE /api/v1/shares/:id
+func (sc *ShareController) Delete(c *gin.Context) {
+	q := database.DB.Where("id = ?", c.Param("id"))
+	if !isAdmin(c) {
+		q = q.Where("owner = ?", c.GetString("username"))
+	}
+	result := q.Delete(&models.ShareLink{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share link", "code": "DATABASE_ERROR"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found", "code": "NOT_FOUND"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// SetVisibility 修改图片可见性
+// PUT /api/v1/images/:uuid/visibility  { visibility: public | unlisted | private }
+func (sc *ShareController) SetVisibility(c *gin.Context) {
+	image, ok := ownedImage(c)
+	if !ok {
+		return
+	}
+	var req visibilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !models.ValidVisibility(req.Visibility) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid visibility", "code": "INVALID_VISIBILITY"})
+		return
+	}
+	if err := database.DB.Model(image).Update("visibility", req.Visibility).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update visibility", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"uuid": image.UUID, "visibility": req.Visibility}})
+}
+
+// SignURL 为图片签发带有效期的 URL（可访问私有图片，可附带变换参数）
+// POST /api/v1/images/:uuid/signed-url  { ttl?, preset? | w?, h?, fit?, fmt?, q? }
+func (sc *ShareController) SignURL(c *gin.Context) {
+	image, ok := ownedImage(c)
+	if !ok {
+		return
+	}
+	var req signedURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "code": "INVALID_PAYLOAD"})
+		return
+	}
+	if req.TTL == 0 {
+		req.TTL = 3600
+	}
+	if req.TTL < 0 || req.TTL > config.AppConfig.SignedURLMaxTTL {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("ttl must be between 1 and %d seconds", config.AppConfig.SignedURLMaxTTL),
+			"code":  "INVALID_TTL",
+		})
+		return
+	}
+
+	transform := url.Values{}
+	set := func(k, v string) {
+		if v != "" && v != "0" {
+			transform.Set(k, v)
+		}
+	}
+	set("preset", req.Preset)
+	set("w", strconv.Itoa(req.W))
+	set("h", strconv.Itoa(req.H))
+	set("fit", req.Fit)
+	set("fmt", req.Fmt)
+	set("q", strconv.Itoa(req.Q))
+	if _, err := services.Transform.Resolve(transform); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transform parameters are not in the preset allow-list", "code": "TRANSFORM_NOT_ALLOWED"})
+		return
+	}
+
+	expires := time.Now().Add(time.Duration(req.TTL) * time.Second)
+	q := services.SignImageURL(image.UUID, expires, transform)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"url":        publicBaseURL(c) + "/img/" + image.UUID + "?" + q.Encode(),
+			"expires_at": expires.Unix(),
+		},
+	})
+}
+
+func shareResult(base string, link *models.ShareLink) gin.H {
+	return gin.H{
+		"id":           link.ID,
+		"slug":         link.Slug,
+		"url":          base + "/s/" + link.Slug,
+		"has_password": link.HasPassword,
+		"expires_at":   link.ExpiresAt,
+		"max_views":    link.MaxViews,
+		"views":        link.Views,
+		"created_at":   link.CreatedAt,
+	}
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"net/http"
+
+	"image-host/database"
+	"image-host/models"
+	"image-host/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TagController struct{}
+
+var Tag = &TagController{}
+
+type tagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// List 标签及使用次数（非管理员仅统计自己的图片）
+// GET /api/v1/tags
+func (tc *TagController) List(c *gin.Context) {
+	uploader := ""
+	if !isAdmin(c) {
+		uploader = c.GetString("username")
+	}
+	counts, err := services.Tags.Counts(uploader)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tags", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": counts})
+}
+
+// Set 替换图片的全部标签
+// PUT /api/v1/images/:uuid/tags  { tags: [] }
+func (tc *TagController) Set(c *gin.Context) {
+	tc.modify(c, services.Tags.Set)
+}
+
+// Add 为图片追加标签
+// POST /api/v1/images/:uuid/tags  { tags: [] }
+func (tc *TagController) Add(c *gin.Context) {
+	tc.modify(c, func(imageID uint, names []string) error {
+		return services.Tags.Add([]uint{imageID}, names)
+	})
+}
+
+// Remove 移除图片的单个标签
+// DELETE /api/v1/images/:uuid/tags/:tag
+func (tc *TagController) Remove(c *gin.Context) {
+	image, ok := ownedImage(c)
+	if !ok {
+		return
+	}
+	names, err := services.NormalizeTags([]string{c.Param("tag")})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "INVALID_TAG"})
+		return
+	}
+	if err := services.Tags.Remove([]uint{image.ID}, names); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tags", "code": "DATABASE_ERROR"})
+		return
+	}
+	tc.respond(c, image)
+}
+
+func (tc *TagController) modify(c *gin.Context, apply func(imageID uint, names []string) error) {
+	image, ok := ownedImage(c)
+	if !ok {
+		return
+	}
+	var req tagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "code": "INVALID_PAYLOAD"})
+		return
+	}
+	names, err := services.NormalizeTags(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "INVALID_TAG"})
+		return
+	}
+	if err := apply(image.ID, names); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tags", "code": "DATABASE_ERROR"})
+		return
+	}
+	tc.respond(c, image)
+}
+
+// respond 返回图片当前的标签
+func (tc *TagController) respond(c *gin.Context, image *models.Image) {
+	images := []models.Image{*image}
+	services.Tags.Fill(images)
+	tags := images[0].Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"uuid": image.UUID, "tags": tags}})
+}
+
+// ownedImage 按 :uuid 查找当前用户可操作的图片（非管理员仅限本人上传），失败时已写入响应
+func ownedImage(c *gin.Context) (*models.Image, bool) {
+	var image models.Image
+	q := database.DB.Where("uuid = ?", c.Param("uuid"))
+	if !isAdmin(c) {
+		q = q.Where("uploader = ?", c.GetString("username"))
+	}
+	if err := q.First(&image).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found", "code": "NOT_FOUND"})
+		return nil, false
+	}
+	return &image, true
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"image-host/database"
+	"image-host/models"
+	"image-host/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TokenController struct{}
+
+var Token = &TokenController{}
+
+type tokenRequest struct {
+	Name      *string   `json:"name"`
+	Scopes    *[]string `json:"scopes"`
+	ExpiresAt *int64    `json:"expires_at"` // unix 秒
+	Days      *int      `json:"days"`
+}
+
+// List 列出当前用户的 API token
+// GET /api/v1/tokens
+func (tc *TokenController) List(c *gin.Context) {
+	var list []models.APIToken
+	if err := database.DB.Where("username = ?", c.GetString("username")).Order("id DESC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tokens", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// Create 创建 API token，明文只返回这一次
+// POST /api/v1/tokens  { name, scopes: ["upload","read","delete"], days?: number, expires_at?: number }
+func (tc *TokenController) Create(c *gin.Context) {
+	var req tokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == nil || strings.TrimSpace(*req.Name) == "" || len(*req.Name) > 64 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "code": "INVALID_PAYLOAD"})
+		return
+	}
+	scopes := []string{models.ScopeUpload, models.ScopeRead}
+	if req.Scopes != nil {
+		scopes = *req.Scopes
+	}
+	scopeStr, err := services.NormalizeScopes(scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "INVALID_SCOPE"})
+		return
+	}
+
+	plain, token, err := services.Tokens.Create(c.GetString("username"), strings.TrimSpace(*req.Name), scopeStr, tokenExpiry(req))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"token": plain,
+			"info":  token,
+		},
+	})
+}
+
+// Update 修改名称、权限或过期时间
+// PUT /api/v1/tokens/:id
+func (tc *TokenController) Update(c *gin.Context) {
+	var token models.APIToken
+	if err := database.DB.Where("id = ? AND username = ?", c.Param("id"), c.GetString("username")).First(&token).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found", "code": "NOT_FOUND"})
+		return
+	}
+	var req tokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "code": "INVALID_PAYLOAD"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > 64 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid name", "code": "INVALID_PAYLOAD"})
+			return
+		}
+		updates["name"] = name
+	}
+	if req.Scopes != nil {
+		scopeStr, err := services.NormalizeScopes(*req.Scopes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "INVALID_SCOPE"})
+			return
+		}
+		updates["scopes"] = scopeStr
+	}
+	if req.ExpiresAt != nil || req.Days != nil {
+		updates["expires_at"] = tokenExpiry(req)
+	}
+	if len(updates) > 0 {
+		if err := database.DB.Model(&token).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update token", "code": "DATABASE_ERROR"})
+			return
+		}
+		database.DB.First(&token, token.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": token})
+}
+
+// Delete 吊销 API token
+// DELETE /api/v1/tokens/:id
+func (tc *TokenController) Delete(c *gin.Context) {
+	result := database.DB.Where("id = ? AND username = ?", c.Param("id"), c.GetString("username")).Delete(&models.APIToken{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token", "code": "DATABASE_ERROR"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found", "code": "NOT_FOUND"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// tokenExpiry 计算过期时间：expires_at 优先，其次 days，均未提供或无效时永不过期
+func tokenExpiry(req tokenRequest) *time.Time {
+	if req.ExpiresAt != nil && *req.ExpiresAt > 0 {
+		t := time.Unix(*req.ExpiresAt, 0)
+		return &t
+	}
+	if req.Days != nil && *req.Days > 0 {
+		t := time.Now().Add(time.Duration(*req.Days) * 24 * time.Hour)
+		return &t
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"image-host/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TrafficController struct{}
+
+var Traffic = &TrafficController{}
+
+// trafficParams 解析 days / limit 参数
+func trafficParams(c *gin.Context) (int, int) {
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if days < 1 || days > 365 {
+		days = 30
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	return days, limit
+}
+
+// Image 单张图片的访问统计
+// GET /api/v1/images/:uuid/stats?days=30&limit=10
+func (tc *TrafficController) Image(c *gin.Context) {
+	image, ok := ownedImage(c)
+	if !ok {
+		return
+	}
+	days, limit := trafficParams(c)
+	scope := services.TrafficScope{ImageID: image.ID, Days: days}
+
+	// 先写入内存中尚未落库的计数
+	_ = services.Traffic.Flush()
+	daily, err := services.Traffic.Daily(scope)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stats", "code": "DATABASE_ERROR"})
+		return
+	}
+	referers, err := services.Traffic.TopReferers(scope, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stats", "code": "DATABASE_ERROR"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"uuid":         image.UUID,
+			"days":         days,
+			"total":        sumTraffic(daily),
+			"daily":        daily,
+			"top_referers": referers,
+		},
+	})
+}
+
+// Summary 访问统计汇总：按天趋势、热门图片与来源域名（非管理员仅统计自己的图片）
+// GET /api/v1/stats/traffic?days=30&limit=10
+func (tc *TrafficController) Summary(c *gin.Context) {
+	days, limit := trafficParams(c)
+	scope := services.TrafficScope{Days: days}
+	if !isAdmin(c) {
+		scope.Uploader = c.GetString("username")
+	}
+
+	_ = services.Traffic.Flush()
+	daily, err := services.Traffic.Daily(scope)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stats", "code": "DATABASE_ERROR"})
+		return
+	}
+	images, err := services.Traffic.TopImages(scope, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stats", "code": "DATABASE_ERROR"})
+		return
+	}
+	referers, err := services.Traffic.TopReferers(scope, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stats", "code": "DATABASE_ERROR"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"days":         days,
+			"total":        sumTraffic(daily),
+			"daily":        daily,
+			"top_images":   images,
+			"top_referers": referers,
+		},
+	})
+}
+
+func sumTraffic(daily []services.TrafficDay) gin.H {
+	var views, bytes int64
+	for _, d := range daily {
+		views += d.Views
+		bytes += d.Bytes
+	}
+	return gin.H{"views": views, "bytes": bytes}
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"image-host/config"
+	"image-host/database"
+	"image-host/models"
+	"image-host/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TrashController struct{}
+
+var Trash = &TrashController{}
+
+// trashItem 回收站中的图片；purge_at 为自动彻底删除的时间（未开启自动清理时为空）
+type trashItem struct {
+	models.Image
+	DeletedAt time.Time  `json:"deleted_at"`
+	PurgeAt   *time.Time `json:"purge_at"`
+}
+
+// trashUploader 非管理员限定为自己的图片；管理员可用 ?uploader= 筛选，为空时为全部
+func trashUploader(c *gin.Context) string {
+	if !isAdmin(c) {
+		return c.GetString("username")
+	}
+	return c.Query("uploader")
+}
+
+// List 回收站列表，按删除时间倒序
+// GET /api/v1/trash?page=&page_size=&uploader=
+func (tc *TrashController) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+
+	q := database.DB.Unscoped().Model(&models.Image{}).Where("deleted_at IS NOT NULL")
+	if uploader := trashUploader(c); uploader != "" {
+		q = q.Where("uploader = ?", uploader)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count images", "code": "DATABASE_ERROR"})
+		return
+	}
+	var images []models.Image
+	if err := q.Order("deleted_at DESC, id DESC").Offset((page - 1) * size).Limit(size).Find(&images).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images", "code": "DATABASE_ERROR"})
+		return
+	}
+	services.Tags.Fill(images)
+
+	items := make([]trashItem, 0, len(images))
+	for _, img := range images {
+		item := trashItem{Image: img, DeletedAt: img.DeletedAt.Time}
+		if days := config.AppConfig.TrashRetentionDays; days > 0 {
+			t := img.DeletedAt.Time.AddDate(0, 0, days)
+			item.PurgeAt = &t
+		}
+		items = append(items, item)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"items":     items,
+			"total":     total,
+			"page":      page,
+			"page_size": size,
+		},
+	})
+}
+
+// Restore 从回收站恢复图片，相册、标签与分享链接随之恢复
+// POST /api/v1/trash/:uuid/restore
+func (tc *TrashController) Restore(c *gin.Context) {
+	image, err := services.Library.FindTrashed(c.Param("uuid"), trashUploader(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found in trash", "code": "NOT_FOUND"})
+		return
+	}
+	if err := services.Library.Restore(image); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore image", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// Purge 彻底删除回收站中的单张图片
+// DELETE /api/v1/trash/:uuid
+func (tc *TrashController) Purge(c *gin.Context) {
+	image, err := services.Library.FindTrashed(c.Param("uuid"), trashUploader(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found in trash", "code": "NOT_FOUND"})
+		return
+	}
+	if err := services.Library.Delete(image); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// Empty 清空回收站（管理员不带 uploader 时清空全部）
+// DELETE /api/v1/trash
+func (tc *TrashController) Empty(c *gin.Context) {
+	n, err := services.Library.PurgeTrash(trashUploader(c), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to empty trash", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"purged": n}})
+}
@@ -1,7 +1,11 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 	"time"
@@ -13,6 +17,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type UploadController struct{}
@@ -37,88 +42,274 @@ func (uc *UploadController) UploadImage(c *gin.Context) {
 	}
 	defer file.Close()
 
-	// 验证图片
-	if err := services.ImageSvc.ValidateImage(header, config.AppConfig.AllowedTypes, config.AppConfig.MaxFileSize); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-			"code":  "VALIDATION_FAILED",
+	image, uerr := uc.storeUpload(c, file, header)
+	if uerr != nil {
+		c.JSON(uerr.status, gin.H{
+			"error": uerr.message,
+			"code":  uerr.code,
 		})
 		return
 	}
 
-	// 处理图片
-	processedImage, err := services.ImageSvc.ProcessImage(file, header)
+	// 返回成功响应
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    imageResult(image),
+	})
+}
+
+// uploadError 单个文件上传失败的原因
+type uploadError struct {
+	status  int
+	code    string
+	message string
+}
+
+// uploadOwner 上传者身份与来源，在请求处理时取出，供并发导入等脱离请求上下文的场景使用
+type uploadOwner struct {
+	username  string
+	role      string
+	ip        string
+	userAgent string
+}
+
+func ownerOf(c *gin.Context) uploadOwner {
+	return uploadOwner{
+		username:  c.GetString("username"),
+		role:      c.GetString("role"),
+		ip:        c.ClientIP(),
+		userAgent: c.GetHeader("User-Agent"),
+	}
+}
+
+// uploadInput 待保存的单个文件；contentType 为来源声明的类型，可为空
+type uploadInput struct {
+	filename    string
+	contentType string
+	data        []byte
+	tags        []string
+	visibility  string
+}
+
+// storeUpload 校验、处理并保存单个表单上传文件（原图 + 缩略图 + 数据库记录）
+func (uc *UploadController) storeUpload(c *gin.Context, file multipart.File, header *multipart.FileHeader) (*models.Image, *uploadError) {
+	// 可选表单字段 tags（可多次或逗号分隔）
+	tags, err := services.NormalizeTags(c.PostFormArray("tags"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to process image",
-			"code":  "PROCESSING_FAILED",
-		})
-		return
+		return nil, &uploadError{http.StatusBadRequest, "INVALID_TAG", err.Error()}
 	}
 
-	// 重置文件指针用于上传
-	file.Seek(0, 0)
+	// 可选表单字段 visibility，默认 public
+	visibility := c.DefaultPostForm("visibility", models.VisibilityPublic)
+	if !models.ValidVisibility(visibility) {
+		return nil, &uploadError{http.StatusBadRequest, "INVALID_VISIBILITY", "Invalid visibility"}
+	}
 
-	// 上传到 R2
-	r2Key, publicURL, err := services.R2.UploadFile(file, header)
+	// 超出全局上限的文件无需读取
+	maxFileSize := config.AppConfig.MaxFileSize
+	if header.Size > maxFileSize {
+		return nil, &uploadError{http.StatusBadRequest, "VALIDATION_FAILED", fmt.Sprintf("file size exceeds limit: %d bytes", maxFileSize)}
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxFileSize+1))
 	if err != nil {
-		// 记录详细错误信息
-		fmt.Printf("R2 upload error: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to upload to storage: " + err.Error(),
-			"code":  "UPLOAD_FAILED",
-		})
-		return
+		return nil, &uploadError{http.StatusBadRequest, "VALIDATION_FAILED", "failed to read file"}
+	}
+
+	return uc.ingest(ownerOf(c), uploadInput{
+		filename:    header.Filename,
+		contentType: header.Header.Get("Content-Type"),
+		data:        data,
+		tags:        tags,
+		visibility:  visibility,
+	})
+}
+
+// ingest 上传流水线：校验类型与大小、配额与游客码限制，处理图片、按内容去重写入存储并保存记录
+func (uc *UploadController) ingest(owner uploadOwner, in uploadInput) (_ *models.Image, ue *uploadError) {
+	// 游客码可收窄允许的类型与单文件大小
+	allowedTypes, maxFileSize := config.AppConfig.AllowedTypes, config.AppConfig.MaxFileSize
+	var guestCode *models.GuestCode
+	if owner.role == models.RoleGuest {
+		var err error
+		if guestCode, err = services.Guest.CodeFor(owner.username); err != nil {
+			return nil, &uploadError{http.StatusForbidden, "GUEST_CODE_DISABLED", "Guest code is no longer usable"}
+		}
+		allowedTypes, maxFileSize = services.Guest.UploadLimits(guestCode)
+	}
+
+	// 验证图片
+	if _, err := services.ImageSvc.ValidateImageBytes(in.data, in.contentType, allowedTypes, maxFileSize); err != nil {
+		return nil, &uploadError{http.StatusBadRequest, "VALIDATION_FAILED", err.Error()}
+	}
+
+	// 配额预检，处理图片前尽早拒绝；写入记录时在事务中再次校验
+	if err := services.Quotas.Check(owner.username, owner.role, int64(len(in.data))); err != nil {
+		var qe *services.QuotaExceededError
+		if errors.As(err, &qe) {
+			return nil, &uploadError{http.StatusForbidden, "QUOTA_EXCEEDED", qe.Error()}
+		}
+		return nil, &uploadError{http.StatusInternalServerError, "DATABASE_ERROR", "Failed to check quota"}
+	}
+
+	// 游客码上传次数，失败时归还
+	if guestCode != nil {
+		if err := services.Guest.ReserveUpload(guestCode.ID); err != nil {
+			if errors.Is(err, services.ErrGuestUploadLimit) {
+				return nil, &uploadError{http.StatusForbidden, "GUEST_UPLOAD_LIMIT", "Guest code upload limit reached"}
+			}
+			return nil, &uploadError{http.StatusInternalServerError, "DATABASE_ERROR", "Failed to check upload limit"}
+		}
+		defer func() {
+			if ue != nil {
+				services.Guest.ReleaseUpload(guestCode.ID)
+			}
+		}()
+	}
+
+	// 处理图片
+	processedImage, err := services.ImageSvc.ProcessImageBytes(in.data)
+	if err != nil {
+		return nil, &uploadError{http.StatusInternalServerError, "PROCESSING_FAILED", "Failed to process image"}
+	}
+
+	// 按内容哈希去重：相同内容复用已存储的对象
+	obj, uerr := uc.storeObject(processedImage)
+	if uerr != nil {
+		return nil, uerr
 	}
 
 	// 保存到数据库
-	uploader := c.GetString("username")
 	image := &models.Image{
 		UUID:         uuid.New().String(),
-		OriginalName: header.Filename,
-		FileName:     header.Filename,
-		FileSize:     header.Size,
+		OriginalName: in.filename,
+		FileName:     in.filename,
+		FileSize:     obj.FileSize,
 		MimeType:     processedImage.MimeType,
 		Width:        processedImage.Width,
 		Height:       processedImage.Height,
-		R2Key:        r2Key,
-		PublicURL:    publicURL,
-		UploadIP:     c.ClientIP(),
-		UserAgent:    c.GetHeader("User-Agent"),
-		Uploader:     uploader,
+		R2Key:        obj.R2Key,
+		PublicURL:    obj.PublicURL,
+		ThumbnailURL: obj.ThumbnailURL,
+		ContentHash:  obj.ContentHash,
+		UploadIP:     owner.ip,
+		UserAgent:    owner.userAgent,
+		Uploader:     owner.username,
+		Visibility:   in.visibility,
 	}
 
-	if err := database.DB.Create(image).Error; err != nil {
-		// 如果数据库保存失败，删除已上传的文件
-		services.R2.DeleteFile(r2Key)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to save image metadata",
-			"code":  "DATABASE_ERROR",
-		})
-		return
+	applyExifFields(image, processedImage.Exif)
+
+	err = services.Quotas.Record(owner.username, owner.role, image.FileSize, func(tx *gorm.DB) error {
+		return tx.Create(image).Error
+	})
+	if err != nil {
+		// 如果数据库保存失败或并发上传已用尽配额，释放对存储对象的引用
+		services.Objects.Release(image)
+		var qe *services.QuotaExceededError
+		if errors.As(err, &qe) {
+			return nil, &uploadError{http.StatusForbidden, "QUOTA_EXCEEDED", qe.Error()}
+		}
+		return nil, &uploadError{http.StatusInternalServerError, "DATABASE_ERROR", "Failed to save image metadata"}
+	}
+
+	if len(in.tags) > 0 {
+		if err := services.Tags.Set(image.ID, in.tags); err == nil {
+			image.Tags = in.tags
+		}
 	}
 
 	// 更新统计信息
-	go uc.updateStats(header.Size)
+	fileSize := image.FileSize
+	services.Jobs.Go(func() { uc.updateStats(fileSize) })
 
-	// 返回成功响应
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data": gin.H{
-			"id":            image.ID,
-			"uuid":          image.UUID,
-			"original_name": image.OriginalName,
-			"file_size":     image.FileSize,
-			"mime_type":     image.MimeType,
-			"width":         image.Width,
-			"height":        image.Height,
-			"public_url":    image.PublicURL,
-			"created_at":    image.CreatedAt,
-		},
-	})
+	return image, nil
+}
+
+// applyExifFields 按 SAVE_EXIF / SAVE_EXIF_GPS 将 EXIF 字段写入图片记录
+func applyExifFields(image *models.Image, exif *services.ExifInfo) {
+	if exif == nil || !config.AppConfig.SaveExif {
+		return
+	}
+	image.Camera = exif.Camera
+	if len(image.Camera) > 128 {
+		image.Camera = image.Camera[:128]
+	}
+	image.TakenAt = exif.TakenAt
+	if config.AppConfig.SaveExifGPS {
+		image.GPSLatitude = exif.GPSLatitude
+		image.GPSLongitude = exif.GPSLongitude
+	}
+}
+
+// storeObject 返回与上传内容一致的存储对象（已增加引用），不存在时写入原图与缩略图
+func (uc *UploadController) storeObject(processedImage *services.ProcessedImage) (*models.StoredObject, *uploadError) {
+	hash := services.ContentHash(processedImage.OriginalBytes)
+	obj, err := services.Objects.Acquire(hash)
+	if err != nil {
+		return nil, &uploadError{http.StatusInternalServerError, "DATABASE_ERROR", "Failed to look up stored object"}
+	}
+	if obj != nil {
+		return obj, nil
+	}
+
+	// 上传到存储
+	// 扩展名取自实际识别的格式，而非客户端文件名
+	r2Key := services.R2.NewKey(processedImage.Ext)
+	publicURL, err := services.R2.PutFile(r2Key, processedImage.OriginalBytes, processedImage.MimeType)
+	if err != nil {
+		// 详细错误只写入日志，其中可能包含存储桶与端点信息
+		log.Printf("Failed to upload %s to storage: %v", r2Key, err)
+		return nil, &uploadError{http.StatusInternalServerError, "STORAGE_ERROR", "Failed to upload to storage"}
+	}
+
+	// 缩略图与原图同目录保存；失败时不影响原图，列表回退使用原图
+	var thumbnailURL string
+	if len(processedImage.ThumbnailBytes) > 0 {
+		thumbMime := processedImage.ThumbnailMimeType()
+		thumbnailURL, err = services.R2.PutFile(services.ThumbnailKey(r2Key, thumbMime), processedImage.ThumbnailBytes, thumbMime)
+		if err != nil {
+			log.Printf("Failed to upload thumbnail for %s: %v", r2Key, err)
+			thumbnailURL = ""
+		}
+	}
+
+	stored := &models.StoredObject{
+		ContentHash:  hash,
+		R2Key:        r2Key,
+		PublicURL:    publicURL,
+		ThumbnailURL: thumbnailURL,
+		FileSize:     int64(len(processedImage.OriginalBytes)),
+	}
+	obj, duplicate, err := services.Objects.Register(stored)
+	if err != nil || duplicate {
+		// 并发上传了相同内容，或登记失败：删除本次写入的文件
+		services.R2.DeleteImageFiles(&models.Image{R2Key: r2Key, ThumbnailURL: thumbnailURL})
+	}
+	if err != nil {
+		return nil, &uploadError{http.StatusInternalServerError, "DATABASE_ERROR", "Failed to save stored object"}
+	}
+	return obj, nil
+}
+
+// imageResult 上传成功后返回给客户端的图片信息
+func imageResult(image *models.Image) gin.H {
+	return gin.H{
+		"id":            image.ID,
+		"uuid":          image.UUID,
+		"original_name": image.OriginalName,
+		"file_size":     image.FileSize,
+		"mime_type":     image.MimeType,
+		"width":         image.Width,
+		"height":        image.Height,
+		"public_url":    image.PublicURL,
+		"thumbnail_url": image.ThumbnailURL,
+		"visibility":    image.Visibility,
+		"tags":          image.Tags,
+		"created_at":    image.CreatedAt,
+	}
 }
 
-// GetImage 获取图片信息
+// GetImage 获取图片信息；非管理员只能查看自己的图片，其他人的图片一律返回 404
 func (uc *UploadController) GetImage(c *gin.Context) {
 	uuid := c.Param("uuid")
 	if uuid == "" {
@@ -130,7 +321,11 @@ func (uc *UploadController) GetImage(c *gin.Context) {
 	}
 
 	var image models.Image
-	if err := database.DB.Where("uuid = ?", uuid).First(&image).Error; err != nil {
+	q := database.DB
+	if !isAdmin(c) {
+		q = q.Where("uploader = ?", c.GetString("username"))
+	}
+	if err := q.Where("uuid = ?", uuid).First(&image).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "Image not found",
 			"code":  "NOT_FOUND",
@@ -138,9 +333,12 @@ func (uc *UploadController) GetImage(c *gin.Context) {
 		return
 	}
 
+	images := []models.Image{image}
+	services.Tags.Fill(images)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data":    image,
+		"data":    images[0],
 	})
 }
 
@@ -214,90 +412,22 @@ func (uc *UploadController) BatchUpload(c *gin.Context) {
 			continue
 		}
 
-		// 验证图片
-		if err := services.ImageSvc.ValidateImage(header, config.AppConfig.AllowedTypes, config.AppConfig.MaxFileSize); err != nil {
-			errors = append(errors, gin.H{
-				"index":    i,
-				"filename": header.Filename,
-				"error":    err.Error(),
-			})
-			file.Close()
-			continue
-		}
-
-		// 处理图片
-		processedImage, err := services.ImageSvc.ProcessImage(file, header)
-		if err != nil {
-			errors = append(errors, gin.H{
-				"index":    i,
-				"filename": header.Filename,
-				"error":    "Failed to process image",
-			})
-			file.Close()
-			continue
-		}
-
-		// 重置文件指针
-		file.Seek(0, 0)
-
-		// 上传到 R2
-		r2Key, publicURL, err := services.R2.UploadFile(file, header)
-		if err != nil {
-			errors = append(errors, gin.H{
-				"index":    i,
-				"filename": header.Filename,
-				"error":    "Failed to upload to storage",
-			})
-			file.Close()
-			continue
-		}
-
-		// 保存到数据库
-		image := &models.Image{
-			UUID:         uuid.New().String(),
-			OriginalName: header.Filename,
-			FileName:     header.Filename,
-			FileSize:     header.Size,
-			MimeType:     processedImage.MimeType,
-			Width:        processedImage.Width,
-			Height:       processedImage.Height,
-			R2Key:        r2Key,
-			PublicURL:    publicURL,
-			UploadIP:     c.ClientIP(),
-			UserAgent:    c.GetHeader("User-Agent"),
-			Uploader:     c.GetString("username"),
-		}
-
-		if err := database.DB.Create(image).Error; err != nil {
-			// 如果数据库保存失败，删除已上传的文件
-			services.R2.DeleteFile(r2Key)
+		image, uerr := uc.storeUpload(c, file, header)
+		file.Close()
+		if uerr != nil {
 			errors = append(errors, gin.H{
 				"index":    i,
 				"filename": header.Filename,
-				"error":    "Failed to save image metadata",
+				"error":    uerr.message,
+				"code":     uerr.code,
 			})
-			file.Close()
 			continue
 		}
 
 		// 添加到成功结果
-		results = append(results, gin.H{
-			"index":         i,
-			"id":            image.ID,
-			"uuid":          image.UUID,
-			"original_name": image.OriginalName,
-			"file_size":     image.FileSize,
-			"mime_type":     image.MimeType,
-			"width":         image.Width,
-			"height":        image.Height,
-			"public_url":    image.PublicURL,
-			"created_at":    image.CreatedAt,
-		})
-
-		// 更新统计信息
-		go uc.updateStats(header.Size)
-
-		file.Close()
+		result := imageResult(image)
+		result["index"] = i
+		results = append(results, result)
 	}
 
 	// 返回批量上传结果
@@ -336,14 +466,26 @@ func (uc *UploadController) ListImages(c *gin.Context) {
 		size = 20
 	}
 
-	var total int64
-	username := c.GetString("username")
-	dbq := database.DB.Model(&models.Image{})
-	// 非 root 仅查看自己的
-	if username != config.AppConfig.DefaultAdmin {
-		dbq = dbq.Where("uploader = ?", username)
+	filter, err := services.ParseImageFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+			"code":  "INVALID_FILTER",
+		})
+		return
+	}
+	if !uc.scopeFilter(c, filter) {
+		return
+	}
+
+	// 带 cursor 参数（首页可为空）时使用游标分页
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		uc.listByCursor(c, filter, cursor, size)
+		return
 	}
-	if err := dbq.Count(&total).Error; err != nil {
+
+	var total int64
+	if err := filter.Apply(database.DB.Model(&models.Image{})).Count(&total).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to count images",
 			"code":  "DATABASE_ERROR",
@@ -352,12 +494,8 @@ func (uc *UploadController) ListImages(c *gin.Context) {
 	}
 
 	var images []models.Image
-	query := database.DB
-	if username != config.AppConfig.DefaultAdmin {
-		query = query.Where("uploader = ?", username)
-	}
-	if err := query.
-		Order("created_at DESC").
+	if err := filter.Apply(database.DB).
+		Order(filter.Order()).
 		Offset((page - 1) * size).
 		Limit(size).
 		Find(&images).Error; err != nil {
@@ -368,6 +506,8 @@ func (uc *UploadController) ListImages(c *gin.Context) {
 		return
 	}
 
+	services.Tags.Fill(images)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": gin.H{
@@ -379,7 +519,88 @@ func (uc *UploadController) ListImages(c *gin.Context) {
 	})
 }
 
-// DeleteImage 删除图片（先删文件，再硬删记录）
+// listByCursor 游标分页：按 (排序字段, id) 定位，翻页期间新上传的图片不会导致重复或遗漏；
+// total 仅在 include_total=1 时计算
+func (uc *UploadController) listByCursor(c *gin.Context, filter *services.ImageFilter, cursor string, size int) {
+	page, err := filter.Page(database.DB, cursor, size)
+	if errors.Is(err, services.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+			"code":  "INVALID_CURSOR",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch images",
+			"code":  "DATABASE_ERROR",
+		})
+		return
+	}
+	services.Tags.Fill(page.Items)
+
+	data := gin.H{
+		"items":       page.Items,
+		"page_size":   size,
+		"next_cursor": page.NextCursor,
+		"prev_cursor": page.PrevCursor,
+		"next":        cursorLink(c, page.NextCursor),
+		"prev":        cursorLink(c, page.PrevCursor),
+	}
+	if v := c.Query("include_total"); v == "1" || v == "true" {
+		var total int64
+		if err := filter.Apply(database.DB.Model(&models.Image{})).Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to count images",
+				"code":  "DATABASE_ERROR",
+			})
+			return
+		}
+		data["total"] = total
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// cursorLink 以当前请求为基础替换 cursor 参数，cursor 为空时返回空串
+func cursorLink(c *gin.Context, cursor string) string {
+	if cursor == "" {
+		return ""
+	}
+	q := c.Request.URL.Query()
+	q.Set("cursor", cursor)
+	q.Del("page")
+	return c.Request.URL.Path + "?" + q.Encode()
+}
+
+// scopeFilter 按调用者身份约束检索条件：非管理员只能检索自己的图片，
+// 指定相册时相册需可访问；失败时已写入响应
+func (uc *UploadController) scopeFilter(c *gin.Context, filter *services.ImageFilter) bool {
+	username := c.GetString("username")
+	if !isAdmin(c) {
+		filter.Uploader = username
+	}
+	if filter.AlbumID != 0 {
+		q := database.DB.Where("id = ?", filter.AlbumID)
+		if !isAdmin(c) {
+			q = q.Where("owner = ?", username)
+		}
+		var album models.Album
+		if err := q.First(&album).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Album not found",
+				"code":  "NOT_FOUND",
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// DeleteImage 删除图片：移入回收站，文件保留至彻底删除
 func (uc *UploadController) DeleteImage(c *gin.Context) {
 	u := c.Param("uuid")
 	if u == "" {
@@ -393,8 +614,8 @@ func (uc *UploadController) DeleteImage(c *gin.Context) {
 	var image models.Image
 	username := c.GetString("username")
 	q := database.DB
-	// 非 root 只能删除自己的
-	if username != config.AppConfig.DefaultAdmin {
+	// 非管理员只能删除自己的
+	if !isAdmin(c) {
 		q = q.Where("uploader = ?", username)
 	}
 	if err := q.Where("uuid = ?", u).First(&image).Error; err != nil {
@@ -405,13 +626,7 @@ func (uc *UploadController) DeleteImage(c *gin.Context) {
 		return
 	}
 
-	// 删除本地文件（忽略不存在错误）
-	if image.R2Key != "" {
-		_ = services.R2.DeleteFile(image.R2Key)
-	}
-
-	// 硬删除数据库记录
-	if err := database.DB.Unscoped().Delete(&image).Error; err != nil {
+	if err := services.Library.Trash(&image); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to delete image record",
 			"code":  "DATABASE_ERROR",
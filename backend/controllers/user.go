@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"image-host/database"
+	"image-host/models"
+	"image-host/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type UserController struct{}
+
+var User = &UserController{}
+
+// isAdmin 当前请求是否来自管理员
+func isAdmin(c *gin.Context) bool {
+	return c.GetString("role") == models.RoleAdmin
+}
+
+type createUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type updateUserRequest struct {
+	Role           *string   `json:"role"`
+	Disabled       *bool     `json:"disabled"`
+	HotlinkPolicy  *string   `json:"hotlink_policy"`
+	HotlinkDomains *[]string `json:"hotlink_domains"`
+	quotaRequest
+}
+
+type resetPasswordRequest struct {
+	NewPassword string `json:"new_password"`
+}
+
+// List 列出账号
+// GET /api/v1/users
+func (uc *UserController) List(c *gin.Context) {
+	var users []models.User
+	if err := database.DB.Order("id ASC").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": users})
+}
+
+// Create 创建账号
+// POST /api/v1/users  { username, password, role? }
+func (uc *UserController) Create(c *gin.Context) {
+	var req createUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Username) == "" || req.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "code": "INVALID_PAYLOAD"})
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if strings.HasPrefix(req.Username, "guest:") || len(req.Username) > 64 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid username", "code": "INVALID_USERNAME"})
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.RoleMember
+	}
+	if !models.ValidUserRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role", "code": "INVALID_ROLE"})
+		return
+	}
+
+	var count int64
+	database.DB.Unscoped().Model(&models.User{}).Where("username = ?", req.Username).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists", "code": "USERNAME_EXISTS"})
+		return
+	}
+
+	hash, err := services.Auth.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+	user := &models.User{Username: req.Username, PasswordHash: hash, Role: req.Role}
+	if err := database.DB.Create(user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": user})
+}
+
+// Update 修改角色、启用/禁用账号、防盗链覆盖或配额
+// PUT /api/v1/users/:id  { role?, disabled?, hotlink_policy?, hotlink_domains?, quota_max_bytes?, quota_max_images?, quota_max_daily? }
+func (uc *UserController) Update(c *gin.Context) {
+	user, ok := uc.findTarget(c)
+	if !ok {
+		return
+	}
+	var req updateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "code": "INVALID_PAYLOAD"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Role != nil {
+		if !models.ValidUserRole(*req.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role", "code": "INVALID_ROLE"})
+			return
+		}
+		updates["role"] = *req.Role
+	}
+	if req.Disabled != nil {
+		updates["disabled"] = *req.Disabled
+	}
+	// 不允许降级或禁用自己，避免失去管理权限
+	if user.Username == c.GetString("username") && len(updates) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot change your own role or status", "code": "SELF_MODIFY"})
+		return
+	}
+	hotlink, ok := hotlinkUpdates(c, hotlinkRequest{Policy: req.HotlinkPolicy, Domains: req.HotlinkDomains})
+	if !ok {
+		return
+	}
+	for k, v := range hotlink {
+		updates[k] = v
+	}
+	if !req.quotaRequest.valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quota", "code": "INVALID_QUOTA"})
+		return
+	}
+	for k, v := range req.quotaRequest.updates() {
+		updates[k] = v
+	}
+	if len(updates) > 0 {
+		if err := database.DB.Model(user).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user", "code": "DATABASE_ERROR"})
+			return
+		}
+		database.DB.First(user, user.ID)
+		services.Hotlink.InvalidateUser(user.Username)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": user})
+}
+
+// ResetPassword 重置指定账号密码，同时使该账号已签发的 JWT 失效
+// POST /api/v1/users/:id/reset-password  { new_password }
+func (uc *UserController) ResetPassword(c *gin.Context) {
+	user, ok := uc.findTarget(c)
+	if !ok {
+		return
+	}
+	var req resetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.NewPassword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "code": "INVALID_PAYLOAD"})
+		return
+	}
+	hash, err := services.Auth.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set new password"})
+		return
+	}
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"password_hash": hash,
+		"token_version": gorm.Expr("token_version + 1"),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save new password", "code": "DATABASE_ERROR"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// Delete 删除账号并吊销其全部 API token。账号为软删除，用户名保留不可再注册，
+// 以免新账号继承原用户名下的图片、相册与分享链接；图片仍归属原用户名，可由 admin 批量转移
+// DELETE /api/v1/users/:id
+func (uc *UserController) Delete(c *gin.Context) {
+	user, ok := uc.findTarget(c)
+	if !ok {
+		return
+	}
+	if user.Username == c.GetString("username") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete yourself", "code": "SELF_MODIFY"})
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("username = ?", user.Username).Delete(&models.APIToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(user).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user", "code": "DATABASE_ERROR"})
+		return
+	}
+	services.Hotlink.InvalidateUser(user.Username)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (uc *UserController) findTarget(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found", "code": "NOT_FOUND"})
+		return nil, false
+	}
+	return &user, true
+}
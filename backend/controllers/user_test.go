@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"image-host/config"
+	"image-host/database"
+	"image-host/middleware"
+	"image-host/models"
+	"image-host/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useTestDB 将 database.DB 替换为临时 SQLite 数据库并迁移全部表，测试结束后恢复
+func useTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_txlock=immediate&_pragma=busy_timeout(10000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	old := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = old
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	return db
+}
+
+func TestResetPasswordRevokesJWT(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := useTestDB(t)
+	old := config.AppConfig
+	config.AppConfig = &config.Config{JWTSecret: "test-secret", JWTExpireHours: 1}
+	t.Cleanup(func() { config.AppConfig = old })
+
+	for _, u := range []struct{ name, role string }{{"root", models.RoleAdmin}, {"alice", models.RoleMember}} {
+		hash, err := services.Auth.HashPassword("old-password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Create(&models.User{Username: u.name, PasswordHash: hash, Role: u.role}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := gin.New()
+	r.POST("/login", Auth.Login)
+	protected := r.Group("", middleware.Auth())
+	protected.GET("/whoami", func(c *gin.Context) { c.String(http.StatusOK, c.GetString("username")) })
+	protected.POST("/users/:id/reset-password", middleware.AdminOnly(), User.ResetPassword)
+
+	do := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(b))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	login := func(username, password string) string {
+		w := do(http.MethodPost, "/login", "", loginRequest{Username: username, Password: password})
+		var resp struct {
+			Data struct {
+				Token string `json:"token"`
+			} `json:"data"`
+		}
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil || resp.Data.Token == "" {
+			t.Fatalf("login %s: %d %s", username, w.Code, w.Body)
+		}
+		return resp.Data.Token
+	}
+
+	admin := login("root", "old-password")
+	alice := login("alice", "old-password")
+	if w := do(http.MethodGet, "/whoami", alice, nil); w.Code != http.StatusOK {
+		t.Fatalf("before reset: %d %s", w.Code, w.Body)
+	}
+
+	var target models.User
+	db.Where("username = ?", "alice").First(&target)
+	if w := do(http.MethodPost, "/users/"+strconv.Itoa(int(target.ID))+"/reset-password", admin,
+		resetPasswordRequest{NewPassword: "new-password"}); w.Code != http.StatusOK {
+		t.Fatalf("reset: %d %s", w.Code, w.Body)
+	}
+
+	// 重置前签发的 JWT 失效，新密码登录得到的 JWT 可用；其他账号不受影响
+	if w := do(http.MethodGet, "/whoami", alice, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("old token after reset: %d %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodGet, "/whoami", login("alice", "new-password"), nil); w.Code != http.StatusOK {
+		t.Fatalf("new token: %d %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodGet, "/whoami", admin, nil); w.Code != http.StatusOK {
+		t.Fatalf("admin token: %d %s", w.Code, w.Body)
+	}
+}
@@ -49,13 +49,16 @@ func InitDatabase() {
 	sqlDB.SetMaxOpenConns(100)          // 最大打开连接数
 	sqlDB.SetConnMaxLifetime(time.Hour) // 连接最大生存时间
 
+	// 旧版本的 users 表没有 role 列，迁移后默认管理员需补齐角色
+	legacyUsers := DB.Migrator().HasTable(&models.User{}) && !DB.Migrator().HasColumn(&models.User{}, "Role")
+
 	// 自动迁移数据库表
 	if err := AutoMigrate(); err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
 	// 初始化默认管理员
-	ensureDefaultAdmin(DB)
+	ensureDefaultAdmin(DB, legacyUsers)
 
 	log.Println("Database connected and migrated successfully")
 }
@@ -67,6 +70,17 @@ func AutoMigrate() error {
 		&models.ImageStats{},
 		&models.User{},
 		&models.GuestCode{},
+		&models.StoredObject{},
+		&models.APIToken{},
+		&models.Album{},
+		&models.AlbumImage{},
+		&models.Tag{},
+		&models.ImageTag{},
+		&models.ShareLink{},
+		&models.HotlinkHit{},
+		&models.ImageTraffic{},
+		&models.RefererTraffic{},
+		&models.UploadCounter{},
 	)
 }
 
@@ -11,13 +11,19 @@ import (
 	"gorm.io/gorm"
 )
 
-func ensureDefaultAdmin(db *gorm.DB) {
+// ensureDefaultAdmin 默认管理员不存在时创建。已存在（含已删除）的账号不做修改，
+// 以免重启后覆盖通过用户管理接口做的降级或禁用；legacy 表示 users 表本次迁移才新增 role 列，
+// 此时旧版本创建的默认管理员需一次性补齐为 admin
+func ensureDefaultAdmin(db *gorm.DB, legacy bool) {
 	var count int64
-	if err := db.Model(&models.User{}).Where("username = ?", config.AppConfig.DefaultAdmin).Count(&count).Error; err != nil {
+	if err := db.Unscoped().Model(&models.User{}).Where("username = ?", config.AppConfig.DefaultAdmin).Count(&count).Error; err != nil {
 		log.Printf("failed to query users: %v", err)
 		return
 	}
 	if count > 0 {
+		if legacy {
+			db.Model(&models.User{}).Where("username = ?", config.AppConfig.DefaultAdmin).Update("role", models.RoleAdmin)
+		}
 		return
 	}
 	// 创建默认管理员
@@ -29,6 +35,7 @@ func ensureDefaultAdmin(db *gorm.DB) {
 	u := &models.User{
 		Username:     config.AppConfig.DefaultAdmin,
 		PasswordHash: string(hashed),
+		Role:         models.RoleAdmin,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
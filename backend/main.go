@@ -1,7 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"image-host/config"
 	"image-host/controllers"
@@ -21,11 +27,14 @@ func main() {
 
 	// 初始化服务
 	services.InitR2Service()
-	log.Println("R2 service initialized successfully")
+	log.Printf("Storage service initialized successfully (driver: %s)", config.AppConfig.StorageDriver)
 
 	services.InitImageService()
 	log.Println("Image service initialized successfully")
 
+	services.InitTransformService()
+	log.Println("Transform service initialized successfully")
+
 	// 初始化控制器
 	controllers.InitUploadController()
 	controllers.InitSystemController()
@@ -38,13 +47,49 @@ func main() {
 	// 启动游客码过期清理任务
 	services.Guest.StartCleanupJob()
 
+	// 启动回收站过期清理任务
+	services.Library.StartTrashPurgeJob()
+
+	// 启动盗链拦截计数写入任务
+	services.Hotlink.StartFlushJob()
+
+	// 启动访问统计写入任务
+	services.Traffic.StartFlushJob()
+
 	// 启动服务器
 	port := config.AppConfig.Port
 	log.Printf("Server starting on port %s", port)
 	log.Printf("Health check: http://localhost:%s/health", port)
 	log.Printf("API endpoint: http://localhost:%s/api/v1", port)
 
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down, waiting for in-flight requests and background jobs...")
+
+	// 先停止接收新请求并等待进行中的上传完成，再停止后台任务并写入剩余计数；
+	// 两个阶段各自计时，请求排空耗尽时间时后台任务（含上传产生的统计更新）仍有完整的等待时间
+	httpCtx, cancelHTTP := context.WithTimeout(context.Background(), time.Duration(config.AppConfig.ShutdownTimeout)*time.Second)
+	defer cancelHTTP()
+	if err := srv.Shutdown(httpCtx); err != nil {
+		log.Printf("HTTP server shutdown: %v", err)
+	}
+	jobsCtx, cancelJobs := context.WithTimeout(context.Background(), time.Duration(config.AppConfig.JobsStopTimeout)*time.Second)
+	defer cancelJobs()
+	if err := services.Jobs.Shutdown(jobsCtx); err != nil {
+		log.Printf("Background jobs shutdown: %v", err)
+	}
+	if sqlDB, err := database.DB.DB(); err == nil {
+		sqlDB.Close()
 	}
+	log.Println("Server stopped")
 }
@@ -1,18 +1,23 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"time"
 
 	"image-host/config"
+	"image-host/database"
+	"image-host/models"
+	"image-host/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type Claims struct {
-	Username string `json:"username"`
+	Username     string `json:"username"`
+	TokenVersion int    `json:"ver"`
 	jwt.RegisteredClaims
 }
 
@@ -34,6 +39,15 @@ func authRequired() gin.HandlerFunc {
 			return
 		}
 		tokenStr := strings.TrimPrefix(auth, "Bearer ")
+
+		// 个人 API token
+		if strings.HasPrefix(tokenStr, services.APITokenPrefix) {
+			if authenticateAPIToken(c, tokenStr) {
+				c.Next()
+			}
+			return
+		}
+
 		claims := &Claims{}
 		token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
 			return []byte(config.AppConfig.JWTSecret), nil
@@ -46,7 +60,149 @@ func authRequired() gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token expired"})
 			return
 		}
+
+		role := models.RoleGuest
+		if strings.HasPrefix(claims.Username, "guest:") {
+			if !checkGuestCode(c, claims.Username) {
+				return
+			}
+		} else {
+			user, ok := lookupUser(c, claims.Username)
+			if !ok {
+				return
+			}
+			// 重置密码后递增版本号，此前签发的 JWT 失效
+			if claims.TokenVersion != user.TokenVersion {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token revoked", "code": "TOKEN_REVOKED"})
+				return
+			}
+			role = user.Role
+		}
+
 		c.Set("username", claims.Username)
+		c.Set("role", role)
+		c.Next()
+	}
+}
+
+// APIKeyAuth 仅接受 API token 的鉴权，供截图/图床工具使用。
+// token 可放在 X-API-Key 头、Authorization: Bearer 或表单字段 key / api_key 中
+func APIKeyAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-API-Key")
+		if key == "" {
+			key = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+		if key == "" {
+			key = c.PostForm("key")
+		}
+		if key == "" {
+			key = c.PostForm("api_key")
+		}
+		if !strings.HasPrefix(key, services.APITokenPrefix) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Missing or invalid API key"})
+			return
+		}
+		if authenticateAPIToken(c, key) {
+			c.Next()
+		}
+	}
+}
+
+// authenticateAPIToken 校验 API token 并写入用户名、角色与权限范围，失败时中止请求
+func authenticateAPIToken(c *gin.Context, plain string) bool {
+	t, err := services.Tokens.Authenticate(plain)
+	if err != nil {
+		msg := "Invalid token"
+		if errors.Is(err, services.ErrAPITokenExpired) {
+			msg = "Token expired"
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": msg})
+		return false
+	}
+	user, ok := lookupUser(c, t.Username)
+	if !ok {
+		return false
+	}
+	c.Set("username", t.Username)
+	c.Set("role", user.Role)
+	c.Set("token_scopes", t.Scopes)
+	return true
+}
+
+// lookupUser 角色以数据库为准，禁用或删除账号后已签发的 token 立即失效
+func lookupUser(c *gin.Context, username string) (*models.User, bool) {
+	var user models.User
+	if err := database.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	if user.Disabled {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Account disabled", "code": "ACCOUNT_DISABLED"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// checkGuestCode 游客会话以游客码状态为准，停用、过期或删除后已签发的 token 立即失效
+func checkGuestCode(c *gin.Context, username string) bool {
+	_, err := services.Guest.CodeFor(username)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrGuestCodeDisabled):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Code disabled", "code": "GUEST_CODE_DISABLED"})
+	case errors.Is(err, services.ErrGuestCodeExpired):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Code expired"})
+	default:
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+	}
+	return false
+}
+
+// RequireScope API token 需包含指定权限；登录会话（JWT）不受限制
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, isToken := c.Get("token_scopes")
+		if isToken && !services.HasScope(scopes.(string), scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Token lacks required scope: " + scope, "code": "INSUFFICIENT_SCOPE"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// SessionOnly 仅允许登录会话访问（账号、token、游客码管理等敏感操作不接受 API token）
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isToken := c.Get("token_scopes"); isToken {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API tokens are not allowed here", "code": "SESSION_REQUIRED"})
+			return
+		}
 		c.Next()
 	}
 }
+
+// RequireRole 仅允许指定角色访问，需在 Auth 之后使用
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden", "code": "FORBIDDEN"})
+	}
+}
+
+// CanWrite 允许上传与删除的角色（admin / member / guest）
+func CanWrite() gin.HandlerFunc {
+	return RequireRole(models.RoleAdmin, models.RoleMember, models.RoleGuest)
+}
+
+// AdminOnly 仅管理员
+func AdminOnly() gin.HandlerFunc {
+	return RequireRole(models.RoleAdmin)
+}
@@ -1,10 +1,13 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"sync"
 	"time"
 
+	"image-host/services"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -32,7 +35,7 @@ func init() {
 	}
 
 	// 定期清理过期的客户端记录
-	go limiter.cleanup()
+	services.Jobs.Every("ratelimit-cleanup", 5*time.Minute, func(context.Context) { limiter.cleanup() })
 }
 
 // RateLimit 速率限制中间件
@@ -87,22 +90,15 @@ func (rl *RateLimiter) allow(clientIP string) bool {
 	return true
 }
 
-// cleanup 定期清理过期的客户端记录
+// cleanup 清理过期的客户端记录
 func (rl *RateLimiter) cleanup() {
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			rl.mu.Lock()
-			now := time.Now()
-			for ip, client := range rl.clients {
-				if now.Sub(client.lastReset) > 2*rl.window {
-					delete(rl.clients, ip)
-				}
-			}
-			rl.mu.Unlock()
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	for ip, client := range rl.clients {
+		if now.Sub(client.lastReset) > 2*rl.window {
+			delete(rl.clients, ip)
 		}
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Album 相册，按 Owner 归属（与 Image.Uploader 相同的用户名形式）
+type Album struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	Name         string         `json:"name" gorm:"type:varchar(128);not null"`
+	Description  string         `json:"description" gorm:"type:varchar(512)"`
+	Owner        string         `json:"owner" gorm:"type:varchar(128);index;not null"`
+	CoverImageID *uint          `json:"cover_image_id"`
+	CoverURL     string         `json:"cover_url" gorm:"-"`   // 封面缩略图地址，查询时填充
+	ImageCount   int64          `json:"image_count" gorm:"-"` // 查询时填充
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+func (Album) TableName() string {
+	return "albums"
+}
+
+// AlbumImage 相册与图片的多对多关系，Position 决定相册内顺序
+type AlbumImage struct {
+	AlbumID   uint      `json:"album_id" gorm:"primaryKey"`
+	ImageID   uint      `json:"image_id" gorm:"primaryKey;index"`
+	Position  int       `json:"position" gorm:"not null;default:0"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (AlbumImage) TableName() string {
+	return "album_images"
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// API token 权限范围
+const (
+	ScopeUpload = "upload"
+	ScopeRead   = "read"
+	ScopeDelete = "delete"
+)
+
+// ValidScope 是否为支持的权限范围
+func ValidScope(scope string) bool {
+	return scope == ScopeUpload || scope == ScopeRead || scope == ScopeDelete
+}
+
+// APIToken 个人 API token，仅保存 SHA-256 哈希
+type APIToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Username   string     `json:"username" gorm:"type:varchar(64);index;not null"`
+	Name       string     `json:"name" gorm:"type:varchar(64);not null"`
+	TokenHash  string     `json:"-" gorm:"type:char(64);uniqueIndex;not null"`
+	Prefix     string     `json:"prefix" gorm:"type:varchar(16)"`          // 明文前若干位，便于识别
+	Scopes     string     `json:"scopes" gorm:"type:varchar(64);not null"` // 逗号分隔，如 upload,read
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"` // nil 表示永不过期
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (APIToken) TableName() string {
+	return "api_tokens"
+}
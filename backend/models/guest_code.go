@@ -8,13 +8,24 @@ import (
 
 // GuestCode 游客码
 type GuestCode struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Code      string         `json:"code" gorm:"type:varchar(64);uniqueIndex;not null"`
-	ExpiresAt *time.Time     `json:"expires_at" gorm:"index"` // nil 表示永久
-	CreatedBy string         `json:"created_by" gorm:"type:varchar(64);not null"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	Code         string         `json:"code" gorm:"type:varchar(64);uniqueIndex;not null"`
+	Label        string         `json:"label" gorm:"type:varchar(64)"`
+	ExpiresAt    *time.Time     `json:"expires_at" gorm:"index"` // nil 表示永久
+	CreatedBy    string         `json:"created_by" gorm:"type:varchar(64);not null"`
+	Disabled     bool           `json:"disabled" gorm:"not null;default:false"` // 停用后不可登录与上传，图片保留
+	MaxLogins    int            `json:"max_logins" gorm:"not null;default:0"`   // 0 表示不限
+	LoginCount   int            `json:"login_count" gorm:"not null;default:0"`
+	MaxUploads   int            `json:"max_uploads" gorm:"not null;default:0"` // 累计上传次数上限（删除不返还），0 表示不限
+	UploadCount  int            `json:"upload_count" gorm:"not null;default:0"`
+	AllowedTypes string         `json:"allowed_types" gorm:"type:varchar(255)"`  // 逗号分隔，须为 ALLOWED_TYPES 子集，空表示沿用全局
+	MaxFileSize  int64          `json:"max_file_size" gorm:"not null;default:0"` // 0 表示沿用 MAX_FILE_SIZE
+	LastUsedAt   *time.Time     `json:"last_used_at"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+
+	QuotaLimits
 }
 
 func (GuestCode) TableName() string {
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// 防盗链策略覆盖：空值表示继承（图片继承用户，用户继承全局 HOTLINK_PROTECTION）
+const (
+	HotlinkInherit = ""
+	HotlinkOn      = "on"  // 强制开启
+	HotlinkOff     = "off" // 关闭（允许任意来源）
+)
+
+// ValidHotlinkPolicy 是否为支持的防盗链策略
+func ValidHotlinkPolicy(p string) bool {
+	return p == HotlinkInherit || p == HotlinkOn || p == HotlinkOff
+}
+
+// HotlinkHit 被拦截的盗链请求，按天、图片、来源域名聚合
+type HotlinkHit struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Date        time.Time `json:"date" gorm:"type:date;not null;uniqueIndex:idx_hotlink_hit,priority:1"`
+	ImageID     uint      `json:"image_id" gorm:"not null;uniqueIndex:idx_hotlink_hit,priority:2"`
+	RefererHost string    `json:"referer_host" gorm:"type:varchar(255);not null;uniqueIndex:idx_hotlink_hit,priority:3"`
+	Hits        int64     `json:"hits" gorm:"not null;default:0"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (HotlinkHit) TableName() string {
+	return "hotlink_hits"
+}
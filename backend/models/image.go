@@ -6,25 +6,46 @@ import (
 	"gorm.io/gorm"
 )
 
+// 图片可见性
+const (
+	VisibilityPublic   = "public"   // 公开：/uploads 与 /img 均可访问
+	VisibilityUnlisted = "unlisted" // 不公开列出：知道地址即可访问，访问规则同 public
+	VisibilityPrivate  = "private"  // 私有：仅能通过分享链接访问
+)
+
+// ValidVisibility 是否为支持的可见性
+func ValidVisibility(v string) bool {
+	return v == VisibilityPublic || v == VisibilityUnlisted || v == VisibilityPrivate
+}
+
 // Image 图片模型
 type Image struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	UUID         string         `json:"uuid" gorm:"type:varchar(36);uniqueIndex;not null"`
-	OriginalName string         `json:"original_name" gorm:"not null"`
-	FileName     string         `json:"file_name" gorm:"not null"`
-	FileSize     int64          `json:"file_size" gorm:"not null"`
-	MimeType     string         `json:"mime_type" gorm:"not null"`
-	Width        int            `json:"width"`
-	Height       int            `json:"height"`
-	R2Key        string         `json:"r2_key" gorm:"not null"`
-	PublicURL    string         `json:"public_url" gorm:"not null"`
-	ThumbnailURL string         `json:"thumbnail_url"`
-	UploadIP     string         `json:"upload_ip"`
-	UserAgent    string         `json:"user_agent"`
-	Uploader     string         `json:"uploader" gorm:"type:varchar(128);index"` // 'root' 或 'guest:<id>'
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID             uint           `json:"id" gorm:"primaryKey;index:idx_images_created_id,priority:2"`
+	UUID           string         `json:"uuid" gorm:"type:varchar(36);uniqueIndex;not null"`
+	OriginalName   string         `json:"original_name" gorm:"not null"`
+	FileName       string         `json:"file_name" gorm:"not null"`
+	FileSize       int64          `json:"file_size" gorm:"not null"`
+	MimeType       string         `json:"mime_type" gorm:"not null"`
+	Width          int            `json:"width"`
+	Height         int            `json:"height"`
+	R2Key          string         `json:"r2_key" gorm:"not null"`
+	PublicURL      string         `json:"public_url" gorm:"not null"`
+	ThumbnailURL   string         `json:"thumbnail_url"`
+	ContentHash    string         `json:"content_hash" gorm:"type:char(64);index"` // SHA-256（hex），相同内容共享同一存储对象
+	UploadIP       string         `json:"upload_ip"`
+	UserAgent      string         `json:"user_agent"`
+	Uploader       string         `json:"uploader" gorm:"type:varchar(128);index"`   // 'root' 或 'guest:<id>'
+	Camera         string         `json:"camera,omitempty" gorm:"type:varchar(128)"` // EXIF 相机型号（SAVE_EXIF 开启时）
+	TakenAt        *time.Time     `json:"taken_at,omitempty"`                        // EXIF 拍摄时间
+	GPSLatitude    *float64       `json:"gps_latitude,omitempty"`                    // EXIF GPS（SAVE_EXIF_GPS 开启时）
+	GPSLongitude   *float64       `json:"gps_longitude,omitempty"`
+	Visibility     string         `json:"visibility" gorm:"type:varchar(16);not null;default:public;index"`
+	HotlinkPolicy  string         `json:"hotlink_policy" gorm:"type:varchar(8)"`                    // 防盗链覆盖：空 / on / off
+	HotlinkDomains string         `json:"hotlink_domains" gorm:"type:varchar(512)"`                 // 额外允许的来源域名，逗号分隔
+	Tags           []string       `json:"tags,omitempty" gorm:"-"`                                  // 查询时填充
+	CreatedAt      time.Time      `json:"created_at" gorm:"index:idx_images_created_id,priority:1"` // 与 ID 组成游标分页索引
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 // TableName 指定表名
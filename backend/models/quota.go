@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// QuotaLimits 配额覆盖，嵌入 User 与 GuestCode。
+// nil 表示使用全局默认（QUOTA_*），0 表示不限
+type QuotaLimits struct {
+	QuotaMaxBytes  *int64 `json:"quota_max_bytes"`  // 总存储字节数
+	QuotaMaxImages *int64 `json:"quota_max_images"` // 图片数量
+	QuotaMaxDaily  *int64 `json:"quota_max_daily"`  // 每日上传数量
+}
+
+// UploadCounter 上传者每日上传次数，用于每日配额。与图片记录独立，删除或移入回收站不会减少；
+// 同时作为配额校验的行锁，使并发上传串行化
+type UploadCounter struct {
+	Uploader string    `json:"uploader" gorm:"type:varchar(128);primaryKey"`
+	Date     time.Time `json:"date" gorm:"type:date;primaryKey"`
+	Uploads  int64     `json:"uploads" gorm:"not null;default:0"`
+}
+
+func (UploadCounter) TableName() string {
+	return "upload_counters"
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// ShareLink 图片分享链接，通过 /s/:slug 访问，可设置密码、过期时间与最大访问次数
+type ShareLink struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	Slug         string     `json:"slug" gorm:"type:varchar(32);uniqueIndex;not null"`
+	ImageID      uint       `json:"image_id" gorm:"index;not null"`
+	Owner        string     `json:"owner" gorm:"type:varchar(128);index;not null"`
+	PasswordHash string     `json:"-" gorm:"type:varchar(255)"`
+	HasPassword  bool       `json:"has_password" gorm:"-"`
+	ExpiresAt    *time.Time `json:"expires_at"`                          // nil 表示永不过期
+	MaxViews     int        `json:"max_views" gorm:"not null;default:0"` // 0 表示不限
+	Views        int        `json:"views" gorm:"not null;default:0"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (ShareLink) TableName() string {
+	return "share_links"
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// StoredObject 按内容哈希去重后的存储对象，多个 Image 可共享同一对象
+type StoredObject struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	ContentHash  string    `json:"content_hash" gorm:"type:char(64);uniqueIndex;not null"` // SHA-256（hex）
+	R2Key        string    `json:"r2_key" gorm:"not null"`
+	PublicURL    string    `json:"public_url" gorm:"not null"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	FileSize     int64     `json:"file_size" gorm:"not null"`
+	RefCount     int       `json:"ref_count" gorm:"not null;default:0"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (StoredObject) TableName() string {
+	return "stored_objects"
+}
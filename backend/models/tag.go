@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Tag 标签，名称全局唯一（小写），各用户共用同一标签记录
+type Tag struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"type:varchar(64);uniqueIndex;not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Tag) TableName() string {
+	return "tags"
+}
+
+// ImageTag 图片与标签的多对多关系
+type ImageTag struct {
+	ImageID   uint      `json:"image_id" gorm:"primaryKey"`
+	TagID     uint      `json:"tag_id" gorm:"primaryKey;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (ImageTag) TableName() string {
+	return "image_tags"
+}
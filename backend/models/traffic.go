@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// ImageTraffic 图片访问量与流量，按天、图片聚合
+type ImageTraffic struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Date      time.Time `json:"date" gorm:"type:date;not null;uniqueIndex:idx_image_traffic,priority:1"`
+	ImageID   uint      `json:"image_id" gorm:"not null;uniqueIndex:idx_image_traffic,priority:2"`
+	Views     int64     `json:"views" gorm:"not null;default:0"`
+	Bytes     int64     `json:"bytes" gorm:"not null;default:0"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (ImageTraffic) TableName() string {
+	return "image_traffic"
+}
+
+// RefererTraffic 按来源域名聚合的访问量与流量
+type RefererTraffic struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Date        time.Time `json:"date" gorm:"type:date;not null;uniqueIndex:idx_referer_traffic,priority:1"`
+	ImageID     uint      `json:"image_id" gorm:"not null;uniqueIndex:idx_referer_traffic,priority:2"`
+	RefererHost string    `json:"referer_host" gorm:"type:varchar(255);not null;uniqueIndex:idx_referer_traffic,priority:3"`
+	Views       int64     `json:"views" gorm:"not null;default:0"`
+	Bytes       int64     `json:"bytes" gorm:"not null;default:0"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (RefererTraffic) TableName() string {
+	return "referer_traffic"
+}
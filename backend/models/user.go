@@ -6,11 +6,31 @@ import (
 	"gorm.io/gorm"
 )
 
+// 用户角色
+const (
+	RoleAdmin    = "admin"    // 管理全部图片、用户与游客码
+	RoleMember   = "member"   // 上传并管理自己的图片
+	RoleReadonly = "readonly" // 仅可查看自己的图片
+	RoleGuest    = "guest"    // 游客码登录（username 形如 guest:<id>），不对应 users 表
+)
+
+// ValidUserRole 是否为可分配给账号的角色
+func ValidUserRole(role string) bool {
+	return role == RoleAdmin || role == RoleMember || role == RoleReadonly
+}
+
 type User struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	Username     string         `json:"username" gorm:"type:varchar(64);uniqueIndex;not null"`
-	PasswordHash string         `json:"-" gorm:"type:varchar(255);not null"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	Username       string         `json:"username" gorm:"type:varchar(64);uniqueIndex;not null"`
+	PasswordHash   string         `json:"-" gorm:"type:varchar(255);not null"`
+	Role           string         `json:"role" gorm:"type:varchar(16);not null;default:member"`
+	Disabled       bool           `json:"disabled" gorm:"not null;default:false"`
+	HotlinkPolicy  string         `json:"hotlink_policy" gorm:"type:varchar(8)"`    // 防盗链覆盖，作用于该用户的全部图片
+	HotlinkDomains string         `json:"hotlink_domains" gorm:"type:varchar(512)"` // 额外允许的来源域名，逗号分隔
+	TokenVersion   int            `json:"-" gorm:"not null;default:0"`              // 写入 JWT，重置密码时递增使已签发的 JWT 失效
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+
+	QuotaLimits
 }
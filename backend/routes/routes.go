@@ -4,9 +4,9 @@ import (
 	"net/http"
 	"time"
 
-	"image-host/config"
 	"image-host/controllers"
 	"image-host/middleware"
+	"image-host/models"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -56,53 +56,168 @@ func SetupRoutes() *gin.Engine {
 			auth.POST("/guest-login", controllers.Auth.GuestLogin)
 		}
 
+		// ShareX / PicGo / Typora 兼容接口（API token 鉴权，扁平响应）
+		compat := api.Group("/compat")
+		{
+			compat.POST("/upload", middleware.APIKeyAuth(), middleware.CanWrite(), middleware.RequireScope(models.ScopeUpload), controllers.Compat.Upload)
+			compat.GET("/delete/:uuid/:sig", controllers.Compat.Delete)
+		}
+
 		// 受保护的路由
 		protected := api.Group("")
 		protected.Use(middleware.Auth())
 		{
 			// 用户信息与改密
 			protected.GET("/auth/me", controllers.Auth.Me)
-			protected.POST("/auth/change-password", controllers.Auth.ChangePassword)
+			protected.POST("/auth/change-password", middleware.SessionOnly(), controllers.Auth.ChangePassword)
 
 			// 图片上传相关路由
 			images := protected.Group("/images")
 			{
 				// 列表与删除
-				images.GET("/", controllers.Upload.ListImages)
-				images.DELETE("/:uuid", controllers.Upload.DeleteImage)
+				images.GET("/", middleware.RequireScope(models.ScopeRead), controllers.Upload.ListImages)
+				images.DELETE("/:uuid", middleware.CanWrite(), middleware.RequireScope(models.ScopeDelete), controllers.Upload.DeleteImage)
 
 				// 上传图片
-				images.POST("/upload", controllers.Upload.UploadImage)
+				images.POST("/upload", middleware.CanWrite(), middleware.RequireScope(models.ScopeUpload), controllers.Upload.UploadImage)
+
+				// 批量操作（按 action 校验 upload / delete 权限）
+				images.POST("/bulk", middleware.CanWrite(), controllers.Bulk.Apply)
+
+				// 归档导入（ZIP / tar.gz）
+				images.POST("/import", middleware.CanWrite(), middleware.RequireScope(models.ScopeUpload), controllers.Import.Archive)
+
+				// 远程地址导入
+				images.POST("/import-url", middleware.CanWrite(), middleware.RequireScope(models.ScopeUpload), controllers.Import.URL)
+
+				// ZIP 导出（与列表相同的过滤参数）
+				images.GET("/export", middleware.RequireScope(models.ScopeRead), controllers.Export.Zip)
+
+				// 搜索（与列表相同的过滤参数）
+				images.GET("/search", middleware.RequireScope(models.ScopeRead), controllers.Upload.ListImages)
 
 				// 获取图片信息
-				images.GET("/:uuid", controllers.Upload.GetImage)
+				images.GET("/:uuid", middleware.RequireScope(models.ScopeRead), controllers.Upload.GetImage)
+
+				// 可见性与分享链接
+				images.PUT("/:uuid/visibility", middleware.CanWrite(), middleware.RequireScope(models.ScopeUpload), controllers.Share.SetVisibility)
+				images.GET("/:uuid/shares", middleware.RequireScope(models.ScopeRead), controllers.Share.List)
+				images.POST("/:uuid/shares", middleware.CanWrite(), middleware.RequireScope(models.ScopeUpload), controllers.Share.Create)
+				images.POST("/:uuid/signed-url", middleware.RequireScope(models.ScopeRead), controllers.Share.SignURL)
+
+				// 访问统计
+				images.GET("/:uuid/stats", middleware.RequireScope(models.ScopeRead), controllers.Traffic.Image)
+
+				// 防盗链覆盖
+				images.PUT("/:uuid/hotlink", middleware.CanWrite(), middleware.RequireScope(models.ScopeUpload), controllers.Hotlink.SetImage)
+
+				// 图片标签
+				images.PUT("/:uuid/tags", middleware.CanWrite(), middleware.RequireScope(models.ScopeUpload), controllers.Tag.Set)
+				images.POST("/:uuid/tags", middleware.CanWrite(), middleware.RequireScope(models.ScopeUpload), controllers.Tag.Add)
+				images.DELETE("/:uuid/tags/:tag", middleware.CanWrite(), middleware.RequireScope(models.ScopeUpload), controllers.Tag.Remove)
 
 				// 获取统计信息
-				images.GET("/stats/summary", controllers.Upload.GetStats)
+				images.GET("/stats/summary", middleware.RequireScope(models.ScopeRead), controllers.Upload.GetStats)
+
+				// 可用的变换预设
+				images.GET("/presets", controllers.Serve.Presets)
 			}
 
 			// 批量上传路由
-			protected.POST("/batch-upload", controllers.Upload.BatchUpload)
+			protected.POST("/batch-upload", middleware.CanWrite(), middleware.RequireScope(models.ScopeUpload), controllers.Upload.BatchUpload)
+
+			// 回收站
+			trash := protected.Group("/trash")
+			{
+				trash.GET("/", middleware.RequireScope(models.ScopeRead), controllers.Trash.List)
+				trash.POST("/:uuid/restore", middleware.CanWrite(), middleware.RequireScope(models.ScopeUpload), controllers.Trash.Restore)
+				trash.DELETE("/:uuid", middleware.CanWrite(), middleware.RequireScope(models.ScopeDelete), controllers.Trash.Purge)
+				trash.DELETE("/", middleware.CanWrite(), middleware.RequireScope(models.ScopeDelete), controllers.Trash.Empty)
+			}
 
-			// 游客码管理（仅 root）
+			// 标签
+			protected.GET("/tags", middleware.RequireScope(models.ScopeRead), controllers.Tag.List)
+
+			// 撤销分享链接
+			protected.DELETE("/shares/:id", middleware.CanWrite(), middleware.RequireScope(models.ScopeUpload), controllers.Share.Delete)
+
+			// 相册
+			albums := protected.Group("/albums")
+			{
+				albums.GET("/", middleware.RequireScope(models.ScopeRead), controllers.Album.List)
+				albums.GET("/:id", middleware.RequireScope(models.ScopeRead), controllers.Album.Get)
+
+				write := albums.Group("")
+				write.Use(middleware.CanWrite(), middleware.RequireScope(models.ScopeUpload))
+				write.POST("/", controllers.Album.Create)
+				write.PUT("/:id", controllers.Album.Update)
+				write.DELETE("/:id", controllers.Album.Delete)
+				write.POST("/:id/images", controllers.Album.AddImages)
+				write.DELETE("/:id/images/:uuid", controllers.Album.RemoveImage)
+				write.PUT("/:id/order", controllers.Album.Reorder)
+			}
+
+			// 个人 API token（仅登录会话，游客不可用）
+			tokens := protected.Group("/tokens")
+			tokens.Use(middleware.SessionOnly(), middleware.RequireRole(models.RoleAdmin, models.RoleMember, models.RoleReadonly))
+			{
+				tokens.GET("/", controllers.Token.List)
+				tokens.POST("/", controllers.Token.Create)
+				tokens.PUT("/:id", controllers.Token.Update)
+				tokens.DELETE("/:id", controllers.Token.Delete)
+			}
+
+			// 游客码管理（仅管理员）
 			guest := protected.Group("/guest-codes")
+			guest.Use(middleware.SessionOnly(), middleware.AdminOnly())
 			{
 				guest.POST("/", controllers.GuestCode.Create)
 				guest.GET("/", controllers.GuestCode.List)
+				guest.PUT("/:id", controllers.GuestCode.Update)
 				guest.DELETE("/:id", controllers.GuestCode.Delete)
 			}
 
+			// 生成工具配置（.sxcu / PicGo），同时创建上传用 API token
+			protected.POST("/compat/config/:client", middleware.SessionOnly(), middleware.RequireRole(models.RoleAdmin, models.RoleMember), controllers.Compat.Config)
+
+			// 账号管理（仅管理员）
+			users := protected.Group("/users")
+			users.Use(middleware.SessionOnly(), middleware.AdminOnly())
+			{
+				users.GET("/", controllers.User.List)
+				users.POST("/", controllers.User.Create)
+				users.PUT("/:id", controllers.User.Update)
+				users.POST("/:id/reset-password", controllers.User.ResetPassword)
+				users.DELETE("/:id", controllers.User.Delete)
+			}
+
+			// 访问统计汇总
+			protected.GET("/stats/traffic", middleware.RequireScope(models.ScopeRead), controllers.Traffic.Summary)
+
+			// 盗链拦截统计（仅管理员）
+			protected.GET("/hotlink/stats", middleware.AdminOnly(), middleware.RequireScope(models.ScopeRead), controllers.Hotlink.Stats)
+
 			// 系统状态
 			system := protected.Group("/system")
+			system.Use(middleware.RequireScope(models.ScopeRead))
 			{
 				system.GET("/status", controllers.System.Status)
 			}
 		}
 	}
 
-	// 静态文件服务
+	// 图片输出与按预设变换
+	r.GET("/img/:uuid", controllers.Serve.Image)
+	r.HEAD("/img/:uuid", controllers.Serve.Image)
+
+	// 分享链接
+	r.GET("/s/:slug", controllers.Serve.Share)
+	r.POST("/s/:slug", controllers.Serve.Share)
+
+	// 静态文件服务；本地上传文件需校验可见性
 	r.Static("/static", "./static")
-	r.Static("/uploads", config.AppConfig.UploadPath)
+	r.GET("/uploads/*filepath", controllers.Serve.Uploads)
+	r.HEAD("/uploads/*filepath", controllers.Serve.Uploads)
 
 	// 404 处理
 	r.NoRoute(func(c *gin.Context) {
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"errors"
+
+	"image-host/database"
+	"image-host/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AlbumService struct{}
+
+var Albums = &AlbumService{}
+
+// FindOrCreate 按名称查找用户的相册，不存在时创建
+func (s *AlbumService) FindOrCreate(owner, name string) (*models.Album, error) {
+	album := &models.Album{}
+	err := database.DB.Where("owner = ? AND name = ?", owner, name).First(album).Error
+	if err == nil {
+		return album, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	album = &models.Album{Name: name, Owner: owner}
+	if err := database.DB.Create(album).Error; err != nil {
+		return nil, err
+	}
+	return album, nil
+}
+
+// AddImages 将图片追加到相册末尾，已在相册中的图片保持原位置；返回新增数量
+func (s *AlbumService) AddImages(albumID uint, imageIDs []uint) (int, error) {
+	added := 0
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var maxPos int
+		if err := tx.Model(&models.AlbumImage{}).Where("album_id = ?", albumID).
+			Select("COALESCE(MAX(position), -1)").Scan(&maxPos).Error; err != nil {
+			return err
+		}
+		for _, id := range imageIDs {
+			maxPos++
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.AlbumImage{
+				AlbumID:  albumID,
+				ImageID:  id,
+				Position: maxPos,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			added += int(result.RowsAffected)
+		}
+		return nil
+	})
+	return added, err
+}
+
+// RemoveImage 从相册移除图片，若为封面则一并清除
+func (s *AlbumService) RemoveImage(albumID, imageID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("album_id = ? AND image_id = ?", albumID, imageID).Delete(&models.AlbumImage{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Album{}).Where("id = ? AND cover_image_id = ?", albumID, imageID).
+			Update("cover_image_id", nil).Error
+	})
+}
+
+// Reorder 按给定顺序重排，未列出的图片保持相对顺序排在其后
+func (s *AlbumService) Reorder(albumID uint, imageIDs []uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var current []models.AlbumImage
+		if err := tx.Where("album_id = ?", albumID).Order("position ASC, created_at ASC").Find(&current).Error; err != nil {
+			return err
+		}
+		order := make([]uint, 0, len(current))
+		listed := map[uint]bool{}
+		inAlbum := map[uint]bool{}
+		for _, ai := range current {
+			inAlbum[ai.ImageID] = true
+		}
+		for _, id := range imageIDs {
+			if inAlbum[id] && !listed[id] {
+				listed[id] = true
+				order = append(order, id)
+			}
+		}
+		for _, ai := range current {
+			if !listed[ai.ImageID] {
+				order = append(order, ai.ImageID)
+			}
+		}
+		for pos, id := range order {
+			if err := tx.Model(&models.AlbumImage{}).Where("album_id = ? AND image_id = ?", albumID, id).
+				Update("position", pos).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DetachImage 图片被删除时移除其相册关系与封面引用
+func (s *AlbumService) DetachImage(imageID uint) error {
+	if err := database.DB.Where("image_id = ?", imageID).Delete(&models.AlbumImage{}).Error; err != nil {
+		return err
+	}
+	return database.DB.Model(&models.Album{}).Where("cover_image_id = ?", imageID).Update("cover_image_id", nil).Error
+}
+
+// Fill 填充相册的图片数量与封面地址。未设置封面或封面已移入回收站、被删除时，
+// 使用相册中最新（ID 最大）的未删除图片；回收站中的图片不计入。按相册批量查询，查询次数与相册数量无关
+func (s *AlbumService) Fill(albums []models.Album) {
+	if len(albums) == 0 {
+		return
+	}
+	ids := make([]uint, 0, len(albums))
+	for _, a := range albums {
+		ids = append(ids, a.ID)
+	}
+	var rows []struct {
+		AlbumID uint
+		Images  int64
+		Newest  uint
+	}
+	if err := database.DB.Model(&models.AlbumImage{}).
+		Select("album_images.album_id, COUNT(*) AS images, MAX(images.id) AS newest").
+		Joins("JOIN images ON images.id = album_images.image_id AND images.deleted_at IS NULL").
+		Where("album_images.album_id IN ?", ids).
+		Group("album_images.album_id").
+		Scan(&rows).Error; err != nil {
+		return
+	}
+	counts := make(map[uint]int64, len(rows))
+	newest := make(map[uint]uint, len(rows))
+	imageIDs := make([]uint, 0, len(rows)+len(albums))
+	for _, r := range rows {
+		counts[r.AlbumID] = r.Images
+		newest[r.AlbumID] = r.Newest
+		imageIDs = append(imageIDs, r.Newest)
+	}
+	for _, a := range albums {
+		if a.CoverImageID != nil {
+			imageIDs = append(imageIDs, *a.CoverImageID)
+		}
+	}
+
+	// 默认作用域排除回收站中的图片
+	var images []models.Image
+	if len(imageIDs) > 0 {
+		if err := database.DB.Where("id IN ?", imageIDs).Find(&images).Error; err != nil {
+			return
+		}
+	}
+	coverURLs := make(map[uint]string, len(images))
+	for _, img := range images {
+		coverURLs[img.ID] = img.ThumbnailURL
+		if img.ThumbnailURL == "" {
+			coverURLs[img.ID] = img.PublicURL
+		}
+	}
+
+	for i := range albums {
+		a := &albums[i]
+		a.ImageCount = counts[a.ID]
+		if a.CoverImageID != nil {
+			if url, ok := coverURLs[*a.CoverImageID]; ok {
+				a.CoverURL = url
+				continue
+			}
+		}
+		a.CoverURL = coverURLs[newest[a.ID]]
+	}
+}
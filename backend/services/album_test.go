@@ -0,0 +1,95 @@
+package services
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"image-host/models"
+
+	"gorm.io/gorm"
+)
+
+// createAlbumImage 写入一张图片并加入相册，返回图片
+func createAlbumImage(t *testing.T, db *gorm.DB, albumID uint) models.Image {
+	t.Helper()
+	n := quotaImageSeq.Add(1)
+	img := models.Image{
+		UUID:         fmt.Sprintf("00000000-0000-0000-0000-%012d", n),
+		OriginalName: "a.png",
+		FileName:     "a.png",
+		FileSize:     100,
+		MimeType:     "image/png",
+		R2Key:        "k",
+		PublicURL:    fmt.Sprintf("/img/%d", n),
+		ThumbnailURL: fmt.Sprintf("/thumb/%d", n),
+		Uploader:     "alice",
+	}
+	if err := db.Create(&img).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Albums.AddImages(albumID, []uint{img.ID}); err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestAlbumFill(t *testing.T) {
+	db := useTestDB(t)
+	albums := make([]models.Album, 4)
+	for i := range albums {
+		albums[i] = models.Album{Name: fmt.Sprintf("album-%d", i), Owner: "alice"}
+		if err := db.Create(&albums[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 0：封面有效；1：封面已移入回收站；2：未设置封面且最新一张在回收站；3：空相册
+	cover := createAlbumImage(t, db, albums[0].ID)
+	createAlbumImage(t, db, albums[0].ID)
+	albums[0].CoverImageID = &cover.ID
+
+	createAlbumImage(t, db, albums[1].ID)
+	newest1 := createAlbumImage(t, db, albums[1].ID)
+	trashedCover := createAlbumImage(t, db, albums[1].ID)
+	albums[1].CoverImageID = &trashedCover.ID
+	if err := Library.Trash(&trashedCover); err != nil {
+		t.Fatal(err)
+	}
+
+	createAlbumImage(t, db, albums[2].ID)
+	newest2 := createAlbumImage(t, db, albums[2].ID)
+	noThumb := newest2
+	db.Model(&noThumb).Update("thumbnail_url", "")
+	trashed := createAlbumImage(t, db, albums[2].ID)
+	if err := Library.Trash(&trashed); err != nil {
+		t.Fatal(err)
+	}
+
+	var queries atomic.Int32
+	count := func(*gorm.DB) { queries.Add(1) }
+	db.Callback().Query().Before("gorm:query").Register("test:count_query", count)
+	db.Callback().Row().Before("gorm:row").Register("test:count_row", count)
+
+	Albums.Fill(albums)
+
+	want := []struct {
+		count int64
+		cover string
+	}{
+		{2, cover.ThumbnailURL},
+		{2, newest1.ThumbnailURL},
+		{2, newest2.PublicURL}, // 无缩略图时使用原图地址
+		{0, ""},
+	}
+	for i, w := range want {
+		if albums[i].ImageCount != w.count || albums[i].CoverURL != w.cover {
+			t.Errorf("album %d: count = %d, cover = %q; want %d, %q", i, albums[i].ImageCount, albums[i].CoverURL, w.count, w.cover)
+		}
+	}
+	if got := queries.Load(); got != 2 {
+		t.Errorf("Fill ran %d queries for %d albums, want 2", got, len(albums))
+	}
+
+	Albums.Fill(nil)
+}
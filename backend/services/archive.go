@@ -0,0 +1,242 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	ErrUnsupportedArchive    = errors.New("unsupported archive format, expected zip or tar.gz")
+	ErrArchiveTooManyEntries = errors.New("archive contains too many files")
+	ErrArchiveTooLarge       = errors.New("archive uncompressed size exceeds limit")
+)
+
+// ArchiveLimits 解压限制，防止解压炸弹：条目数、单个文件与解压总量均有上限，
+// 读取时按实际解压字节计数，不信任归档中声明的大小；tar.gz 的跳过与被拒条目同样计入总量
+type ArchiveLimits struct {
+	MaxEntries   int
+	MaxEntrySize int64
+	MaxTotalSize int64
+}
+
+// ArchiveEntry 归档中的单个文件。Err 非空表示该条目被拒绝（路径非法、超出大小等），
+// Skipped 表示非普通文件或系统文件（如 __MACOSX、.DS_Store）
+type ArchiveEntry struct {
+	Index   int
+	Name    string // 清理后的相对路径
+	Dir     string // 所在目录，根目录为空
+	Data    []byte
+	Err     error
+	Skipped bool
+}
+
+// WalkArchive 按顺序读取 ZIP 或 tar.gz 归档中的文件并逐个交给 fn；
+// 超出条目数或解压总量限制时停止并返回错误，已交给 fn 的条目不受影响
+func WalkArchive(r io.ReaderAt, size int64, limits ArchiveLimits, fn func(*ArchiveEntry)) error {
+	magic := make([]byte, 4)
+	if _, err := r.ReadAt(magic, 0); err != nil {
+		return ErrUnsupportedArchive
+	}
+	w := &archiveWalker{limits: limits, fn: fn}
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		return w.walkZip(r, size)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return w.walkTarGz(io.NewSectionReader(r, 0, size))
+	}
+	return ErrUnsupportedArchive
+}
+
+type archiveWalker struct {
+	limits ArchiveLimits
+	fn     func(*ArchiveEntry)
+	index  int
+	total  int64
+}
+
+func (w *archiveWalker) walkZip(r io.ReaderAt, size int64) error {
+	// 不安全路径由 cleanArchivePath 逐条拒绝，不因此放弃整个归档
+	zr, err := zip.NewReader(r, size)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return fmt.Errorf("invalid zip archive: %v", err)
+	}
+	// ZIP 目录可预先统计，超出条目数时不导入任何文件
+	if w.limits.MaxEntries > 0 {
+		files := 0
+		for _, f := range zr.File {
+			if !f.FileInfo().IsDir() {
+				files++
+			}
+		}
+		if files > w.limits.MaxEntries {
+			return ErrArchiveTooManyEntries
+		}
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		entry, ok, err := w.next(f.Name, f.Mode().IsRegular())
+		if err != nil {
+			return err
+		}
+		if ok {
+			// 声明大小超限时不解压
+			if f.UncompressedSize64 > uint64(w.limits.MaxEntrySize) {
+				entry.Err = fmt.Errorf("file size exceeds limit: %d bytes", w.limits.MaxEntrySize)
+			} else if rc, err := f.Open(); err != nil {
+				entry.Err = fmt.Errorf("failed to open entry: %v", err)
+			} else {
+				err = w.read(entry, rc)
+				rc.Close()
+				if err != nil {
+					return err
+				}
+			}
+		}
+		w.fn(entry)
+	}
+	return nil
+}
+
+func (w *archiveWalker) walkTarGz(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("invalid gzip stream: %v", err)
+	}
+	defer gz.Close()
+	// tar 跳过条目时同样需要解压其内容，因此在 gzip 流上统计全部解压字节，
+	// 而不仅是交给 read 的条目
+	counter := &archiveCounter{r: gz, max: w.limits.MaxTotalSize}
+	tr := tar.NewReader(counter)
+	for {
+		hdr, err := tr.Next()
+		if counter.exceeded() {
+			return ErrArchiveTooLarge
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %v", err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		}
+		entry, ok, err := w.next(hdr.Name, hdr.FileInfo().Mode().IsRegular())
+		if err != nil {
+			return err
+		}
+		if ok {
+			if hdr.Size > w.limits.MaxEntrySize {
+				entry.Err = fmt.Errorf("file size exceeds limit: %d bytes", w.limits.MaxEntrySize)
+			} else if err := w.read(entry, tr); err != nil {
+				return err
+			}
+			if counter.exceeded() {
+				return ErrArchiveTooLarge
+			}
+		}
+		w.fn(entry)
+	}
+}
+
+// archiveCounter 统计已解压的字节数，超出 max 后的读取一律返回 ErrArchiveTooLarge
+type archiveCounter struct {
+	r   io.Reader
+	n   int64
+	max int64
+}
+
+func (c *archiveCounter) Read(p []byte) (int, error) {
+	if c.exceeded() {
+		return 0, ErrArchiveTooLarge
+	}
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.exceeded() {
+		return n, ErrArchiveTooLarge
+	}
+	return n, err
+}
+
+func (c *archiveCounter) exceeded() bool {
+	return c.max > 0 && c.n > c.max
+}
+
+// next 登记一个条目并校验路径；ok 为 false 表示无需读取内容
+func (w *archiveWalker) next(name string, regular bool) (*ArchiveEntry, bool, error) {
+	w.index++
+	if w.limits.MaxEntries > 0 && w.index > w.limits.MaxEntries {
+		return nil, false, ErrArchiveTooManyEntries
+	}
+	entry := &ArchiveEntry{Index: w.index - 1, Name: name}
+	clean, ok := cleanArchivePath(name)
+	if !ok {
+		entry.Err = fmt.Errorf("invalid path")
+		return entry, false, nil
+	}
+	entry.Name = clean
+	if dir := path.Dir(clean); dir != "." {
+		entry.Dir = dir
+	}
+	if !regular || hiddenArchivePath(clean) {
+		entry.Skipped = true
+		return entry, false, nil
+	}
+	return entry, true, nil
+}
+
+// read 读取条目内容，按实际字节数检查单个文件与总量限制；超出总量时返回错误
+func (w *archiveWalker) read(entry *ArchiveEntry, r io.Reader) error {
+	data, err := io.ReadAll(io.LimitReader(r, w.limits.MaxEntrySize+1))
+	w.total += int64(len(data))
+	if w.limits.MaxTotalSize > 0 && w.total > w.limits.MaxTotalSize {
+		return ErrArchiveTooLarge
+	}
+	switch {
+	case err != nil:
+		entry.Err = fmt.Errorf("failed to read entry: %v", err)
+	case int64(len(data)) > w.limits.MaxEntrySize:
+		entry.Err = fmt.Errorf("file size exceeds limit: %d bytes", w.limits.MaxEntrySize)
+	default:
+		entry.Data = data
+	}
+	return nil
+}
+
+// cleanArchivePath 规范化条目路径，拒绝绝对路径、盘符与 .. 等可能越出目录的路径（zip slip）
+func cleanArchivePath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, ":") || strings.ContainsRune(name, 0) {
+		return "", false
+	}
+	for _, seg := range strings.Split(name, "/") {
+		if seg == ".." {
+			return "", false
+		}
+	}
+	clean := path.Clean(name)
+	if clean == "." || strings.HasPrefix(clean, "../") {
+		return "", false
+	}
+	return clean, true
+}
+
+// hiddenArchivePath 系统生成的隐藏文件或目录（.DS_Store、__MACOSX 等）
+func hiddenArchivePath(name string) bool {
+	for _, seg := range strings.Split(name, "/") {
+		if strings.HasPrefix(seg, ".") || seg == "__MACOSX" || strings.EqualFold(seg, "Thumbs.db") {
+			return true
+		}
+	}
+	return false
+}
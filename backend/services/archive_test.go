@@ -0,0 +1,211 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type archiveFile struct {
+	name string
+	size int // 内容为 size 个 0 字节，便于构造高压缩比的条目
+	typ  byte
+}
+
+func buildTarGz(t *testing.T, files []archiveFile) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		typ := f.typ
+		if typ == 0 {
+			typ = tar.TypeReg
+		}
+		size := int64(f.size)
+		if typ != tar.TypeReg {
+			size = 0
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: size, Typeflag: typ, Linkname: "target"}); err != nil {
+			t.Fatalf("tar header %q: %v", f.name, err)
+		}
+		if _, err := tw.Write(make([]byte, size)); err != nil {
+			t.Fatalf("tar write %q: %v", f.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func buildZip(t *testing.T, files []archiveFile) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatalf("zip create %q: %v", f.name, err)
+		}
+		if _, err := w.Write(make([]byte, f.size)); err != nil {
+			t.Fatalf("zip write %q: %v", f.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func walkBytes(data []byte, limits ArchiveLimits) ([]*ArchiveEntry, error) {
+	var entries []*ArchiveEntry
+	err := WalkArchive(bytes.NewReader(data), int64(len(data)), limits, func(e *ArchiveEntry) {
+		entries = append(entries, e)
+	})
+	return entries, err
+}
+
+var testArchiveLimits = ArchiveLimits{MaxEntries: 100, MaxEntrySize: 64 << 10, MaxTotalSize: 1 << 20}
+
+func TestWalkArchiveTarGzBomb(t *testing.T) {
+	cases := map[string][]archiveFile{
+		// 跳过的条目不经过 read，但 tar 仍需解压其内容
+		"skipped": {
+			{name: "__MACOSX/a.png", size: 600 << 10},
+			{name: "photos/.DS_Store", size: 600 << 10},
+			{name: "b.png", size: 10},
+		},
+		// 声明大小超出单文件上限而被拒绝的条目
+		"oversized": {
+			{name: "a.png", size: 700 << 10},
+			{name: "b.png", size: 700 << 10},
+			{name: "c.png", size: 10},
+		},
+	}
+	for name, files := range cases {
+		data := buildTarGz(t, files)
+		if len(data) > 64<<10 {
+			t.Fatalf("%s: archive is %d bytes, want a small bomb", name, len(data))
+		}
+		if _, err := walkBytes(data, testArchiveLimits); !errors.Is(err, ErrArchiveTooLarge) {
+			t.Errorf("%s: err = %v, want ErrArchiveTooLarge", name, err)
+		}
+	}
+
+	// 未超出总量时正常读取，跳过与超限条目按原样报告
+	data := buildTarGz(t, []archiveFile{
+		{name: "__MACOSX/a.png", size: 100},
+		{name: "big.png", size: 100 << 10},
+		{name: "link.png", typ: tar.TypeSymlink},
+		{name: "dir/ok.png", size: 10},
+	})
+	entries, err := walkBytes(data, testArchiveLimits)
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4", len(entries))
+	}
+	if !entries[0].Skipped || entries[1].Err == nil || !entries[2].Skipped {
+		t.Fatalf("unexpected entries: %+v %+v %+v", entries[0], entries[1], entries[2])
+	}
+	if e := entries[3]; e.Err != nil || e.Name != "dir/ok.png" || e.Dir != "dir" || len(e.Data) != 10 {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+}
+
+func TestWalkArchiveZipBomb(t *testing.T) {
+	// 每个条目均未超出单文件上限，但解压总量超限
+	var files []archiveFile
+	for i := 0; i < 20; i++ {
+		files = append(files, archiveFile{name: strings.Repeat("a", i+1) + ".png", size: 60 << 10})
+	}
+	data := buildZip(t, files)
+	entries, err := walkBytes(data, testArchiveLimits)
+	if !errors.Is(err, ErrArchiveTooLarge) {
+		t.Fatalf("err = %v, want ErrArchiveTooLarge", err)
+	}
+	if len(entries) >= len(files) {
+		t.Fatalf("walk delivered %d entries after exceeding the total size", len(entries))
+	}
+
+	// 超出单文件上限的条目不解压
+	data = buildZip(t, []archiveFile{{name: "big.png", size: 100 << 10}, {name: "ok.png", size: 10}})
+	entries, err = walkBytes(data, testArchiveLimits)
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Err == nil || entries[0].Data != nil || entries[1].Err != nil {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestWalkArchivePathTraversal(t *testing.T) {
+	names := []string{"../evil.png", "a/../../evil.png", "/etc/evil.png", "C:/evil.png", "..\\evil.png", "ok/../fine.png"}
+	var files []archiveFile
+	for _, n := range names {
+		files = append(files, archiveFile{name: n, size: 10})
+	}
+	for format, data := range map[string][]byte{"zip": buildZip(t, files), "tar.gz": buildTarGz(t, files)} {
+		entries, err := walkBytes(data, testArchiveLimits)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if len(entries) != len(names) {
+			t.Fatalf("%s: got %d entries, want %d", format, len(entries), len(names))
+		}
+		for i, e := range entries {
+			if e.Err == nil || e.Data != nil {
+				t.Errorf("%s: entry %q accepted as %q", format, names[i], e.Name)
+			}
+		}
+	}
+
+	for name, want := range map[string]string{"a/b.png": "a/b.png", "./a//b.png": "a/b.png", "a\\b.png": "a/b.png"} {
+		if got, ok := cleanArchivePath(name); !ok || got != want {
+			t.Errorf("cleanArchivePath(%q) = %q %v, want %q", name, got, ok, want)
+		}
+	}
+}
+
+func TestWalkArchiveEntryLimit(t *testing.T) {
+	limits := testArchiveLimits
+	limits.MaxEntries = 3
+	var files []archiveFile
+	for i := 0; i < 4; i++ {
+		files = append(files, archiveFile{name: strings.Repeat("a", i+1) + ".png", size: 10})
+	}
+
+	// ZIP 预先统计目录，超限时不交付任何条目
+	entries, err := walkBytes(buildZip(t, files), limits)
+	if !errors.Is(err, ErrArchiveTooManyEntries) || len(entries) != 0 {
+		t.Fatalf("zip: err = %v, entries = %d", err, len(entries))
+	}
+	// tar.gz 只能顺序读取，在第 4 个条目处停止
+	entries, err = walkBytes(buildTarGz(t, files), limits)
+	if !errors.Is(err, ErrArchiveTooManyEntries) || len(entries) != 3 {
+		t.Fatalf("tar.gz: err = %v, entries = %d", err, len(entries))
+	}
+
+	// 恰好达到上限时允许
+	if _, err := walkBytes(buildTarGz(t, files[:3]), limits); err != nil {
+		t.Fatalf("tar.gz at limit: %v", err)
+	}
+	if _, err := walkBytes(buildZip(t, files[:3]), limits); err != nil {
+		t.Fatalf("zip at limit: %v", err)
+	}
+}
+
+func TestWalkArchiveUnsupported(t *testing.T) {
+	for _, data := range [][]byte{[]byte("not an archive"), {0x1f, 0x8b, 0, 0}, nil} {
+		if _, err := walkBytes(data, testArchiveLimits); err == nil {
+			t.Errorf("walk(%q): expected error", data)
+		}
+	}
+}
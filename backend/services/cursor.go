@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"image-host/database"
+	"image-host/models"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// pageCursor 游标内容：排序字段与方向、边界记录的排序值与 ID，以及翻页方向。
+// 对外以 base64url(JSON) 形式出现，客户端应视为不透明字符串
+type pageCursor struct {
+	Sort   string      `json:"s"`
+	Desc   bool        `json:"d,omitempty"`
+	Value  interface{} `json:"v"`
+	ID     uint        `json:"i"`
+	Before bool        `json:"b,omitempty"` // true 表示取边界之前的一页（上一页）
+}
+
+// ImagePage 游标分页结果，NextCursor / PrevCursor 为空表示没有更多
+type ImagePage struct {
+	Items      []models.Image
+	NextCursor string
+	PrevCursor string
+}
+
+func encodeCursor(c *pageCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var c pageCursor
+	if err := dec.Decode(&c); err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// Page 按 (排序字段, id) 做 keyset 分页；cursor 为空时取第一页。
+// 游标与当前排序不一致时返回 ErrInvalidCursor
+func (f *ImageFilter) Page(db *gorm.DB, cursor string, size int) (*ImagePage, error) {
+	before := false
+	q := f.Apply(db)
+	if cursor != "" {
+		cur, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cur.Sort != f.Sort || cur.Desc != f.Desc {
+			return nil, fmt.Errorf("%w: sort does not match", ErrInvalidCursor)
+		}
+		value, err := f.parseCursorValue(cur.Value)
+		if err != nil {
+			return nil, err
+		}
+		before = cur.Before
+
+		// 向后翻页：降序取更小的值，升序取更大的值；向前翻页相反
+		op := ">"
+		if f.Desc != before {
+			op = "<"
+		}
+		col := sortColumns[f.Sort]
+		q = q.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND images.id %s ?))", col, op, col, op), value, value, cur.ID)
+	}
+
+	// 向前翻页时反向排序取最近的记录，再翻转回正常顺序
+	order := f.Order()
+	if before {
+		reversed := *f
+		reversed.Desc = !f.Desc
+		order = reversed.Order()
+	}
+
+	var items []models.Image
+	if err := q.Order(order).Limit(size + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	more := len(items) > size
+	if more {
+		items = items[:size]
+	}
+	if before {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	page := &ImagePage{Items: items}
+	if len(items) == 0 {
+		return page, nil
+	}
+	// 向后翻页时，若带有游标则一定存在上一页；向前翻页时一定存在下一页
+	hasNext, hasPrev := more, cursor != ""
+	if before {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext {
+		page.NextCursor = f.cursorFor(&items[len(items)-1], false)
+	}
+	if hasPrev {
+		page.PrevCursor = f.cursorFor(&items[0], true)
+	}
+	return page, nil
+}
+
+// cursorFor 以某条记录为边界生成游标
+func (f *ImageFilter) cursorFor(img *models.Image, before bool) string {
+	var value interface{}
+	switch f.Sort {
+	case "created_at":
+		value = img.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case "size":
+		value = img.FileSize
+	case "name":
+		value = img.OriginalName
+	case "width":
+		value = img.Width
+	case "height":
+		value = img.Height
+	case "position":
+		var pos int
+		database.DB.Model(&models.AlbumImage{}).
+			Where("album_id = ? AND image_id = ?", f.AlbumID, img.ID).
+			Select("position").Scan(&pos)
+		value = pos
+	}
+	return encodeCursor(&pageCursor{Sort: f.Sort, Desc: f.Desc, Value: value, ID: img.ID, Before: before})
+}
+
+// parseCursorValue 将游标中的值还原为对应列的类型
+func (f *ImageFilter) parseCursorValue(v interface{}) (interface{}, error) {
+	switch f.Sort {
+	case "created_at":
+		s, ok := v.(string)
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return t, nil
+	case "name":
+		s, ok := v.(string)
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		return s, nil
+	default:
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		i, err := n.Int64()
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return i, nil
+	}
+}
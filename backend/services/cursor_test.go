@@ -0,0 +1,187 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"image-host/models"
+
+	"gorm.io/gorm"
+)
+
+// seedCursorImages 写入 n 张图片，每 3 张共享同一 created_at，用于检验同值时按 id 排序
+func seedCursorImages(t *testing.T, db *gorm.DB, n int) {
+	base := time.Date(2025, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	for i := 0; i < n; i++ {
+		img := models.Image{
+			UUID:         fmt.Sprintf("00000000-0000-0000-0000-%012d", i),
+			OriginalName: fmt.Sprintf("img-%02d.png", i%4),
+			FileName:     "f",
+			FileSize:     int64(100 + i%5),
+			MimeType:     "image/png",
+			R2Key:        "k",
+			PublicURL:    "u",
+			Uploader:     "alice",
+			Visibility:   models.VisibilityPublic,
+			CreatedAt:    base.Add(time.Duration(i/3) * time.Second),
+		}
+		if err := db.Create(&img).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func pageIDs(items []models.Image) []uint {
+	ids := make([]uint, len(items))
+	for i, img := range items {
+		ids[i] = img.ID
+	}
+	return ids
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, c := range []*pageCursor{
+		{Sort: "created_at", Desc: true, Value: "2025-01-02T03:04:05.123456Z", ID: 7},
+		{Sort: "size", Value: int64(1024), ID: 3, Before: true},
+		{Sort: "name", Desc: true, Value: "a \"quoted\" 名字.png", ID: 1},
+	} {
+		got, err := decodeCursor(encodeCursor(c))
+		if err != nil {
+			t.Fatalf("decodeCursor: %v", err)
+		}
+		f := &ImageFilter{Sort: c.Sort}
+		value, err := f.parseCursorValue(got.Value)
+		if err != nil {
+			t.Fatalf("parseCursorValue(%v): %v", got.Value, err)
+		}
+		want := c.Value
+		if c.Sort == "created_at" {
+			want, _ = time.Parse(time.RFC3339Nano, c.Value.(string))
+		}
+		if got.Sort != c.Sort || got.Desc != c.Desc || got.ID != c.ID || got.Before != c.Before || value != want {
+			t.Fatalf("round trip: got %+v (%v), want %+v", got, value, c)
+		}
+	}
+}
+
+func TestCursorInvalid(t *testing.T) {
+	b64 := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	for _, s := range []string{
+		"",
+		"not base64!",
+		"////",
+		b64("not json"),
+		b64("null"),
+		b64("[]"),
+		b64(`{"s":"created_at","v":"2025-01-01T00:00:00Z"}`),        // 缺少 id
+		b64(`{"s":"created_at","v":"2025-01-01T00:00:00Z","i":-1}`), // 负数 id
+		b64(`{"s":"created_at","v":"x","i":"1"}`),
+		b64(`{"s":"created_at","v":{"a":1},"i":1e400}`),
+	} {
+		if _, err := decodeCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("decodeCursor(%q): err = %v, want ErrInvalidCursor", s, err)
+		}
+	}
+
+	// 排序值类型与字段不符
+	for _, tc := range []struct {
+		sort  string
+		value interface{}
+	}{
+		{"created_at", nil},
+		{"created_at", "yesterday"},
+		{"created_at", []interface{}{1}},
+		{"name", map[string]interface{}{}},
+		{"size", "big"},
+		{"size", nil},
+		{"width", "1.5"},
+	} {
+		f := &ImageFilter{Sort: tc.sort}
+		if _, err := f.parseCursorValue(tc.value); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("parseCursorValue(%s, %v): err = %v", tc.sort, tc.value, err)
+		}
+	}
+}
+
+func TestPageTiesOrderedByID(t *testing.T) {
+	db := useTestDB(t)
+	seedCursorImages(t, db, 10)
+
+	for _, desc := range []bool{true, false} {
+		f := &ImageFilter{Sort: "created_at", Desc: desc}
+		var all []models.Image
+		if err := db.Order(f.Order()).Find(&all).Error; err != nil {
+			t.Fatal(err)
+		}
+		want := pageIDs(all)
+
+		// 每页 2 条，页边界落在相同 created_at 的记录之间
+		var got []uint
+		var cursors []string
+		cursor := ""
+		for {
+			page, err := f.Page(db, cursor, 2)
+			if err != nil {
+				t.Fatalf("desc=%v Page: %v", desc, err)
+			}
+			got = append(got, pageIDs(page.Items)...)
+			cursors = append(cursors, cursor)
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("desc=%v forward pages = %v, want %v", desc, got, want)
+		}
+		for i := 1; i < len(all); i++ {
+			a, b := all[i-1], all[i]
+			if a.CreatedAt.Equal(b.CreatedAt) && (a.ID < b.ID) != !desc {
+				t.Fatalf("desc=%v ties not ordered by id: %d then %d", desc, a.ID, b.ID)
+			}
+		}
+
+		// 从最后一页用 PrevCursor 向前翻，得到相同的顺序
+		page, err := f.Page(db, cursors[len(cursors)-1], 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		back := pageIDs(page.Items)
+		for page.PrevCursor != "" {
+			page, err = f.Page(db, page.PrevCursor, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			back = append(pageIDs(page.Items), back...)
+		}
+		if fmt.Sprint(back) != fmt.Sprint(want) {
+			t.Fatalf("desc=%v backward pages = %v, want %v", desc, back, want)
+		}
+	}
+}
+
+func TestPageRejectsCursorFromOtherSort(t *testing.T) {
+	db := useTestDB(t)
+	seedCursorImages(t, db, 5)
+
+	byDate := &ImageFilter{Sort: "created_at", Desc: true}
+	page, err := byDate.Page(db, "", 2)
+	if err != nil || page.NextCursor == "" {
+		t.Fatalf("first page: %v %+v", err, page)
+	}
+	for _, other := range []*ImageFilter{
+		{Sort: "created_at", Desc: false},
+		{Sort: "size", Desc: true},
+		{Sort: "name", Desc: true},
+	} {
+		if _, err := other.Page(db, page.NextCursor, 2); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("sort %s desc=%v accepted cursor from -created_at: err = %v", other.Sort, other.Desc, err)
+		}
+	}
+	if _, err := byDate.Page(db, "garbage", 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("garbage cursor: err = %v", err)
+	}
+}
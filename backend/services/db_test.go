@@ -0,0 +1,36 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"image-host/database"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useTestDB 将 database.DB 替换为临时 SQLite 数据库并迁移全部表，测试结束后恢复。
+// 事务以 IMMEDIATE 方式开始，并发事务按开始顺序串行执行（对应 MySQL 中的行锁等待）
+func useTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") +
+		"?_txlock=immediate&_time_format=sqlite&_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	old := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = old
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	return db
+}
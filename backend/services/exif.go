@@ -0,0 +1,555 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"strings"
+	"time"
+)
+
+// ExifInfo 从 EXIF 中提取的常用字段
+type ExifInfo struct {
+	Orientation  int // 1-8，0 表示未记录
+	Camera       string
+	TakenAt      *time.Time
+	GPSLatitude  *float64
+	GPSLongitude *float64
+}
+
+// EXIF 标签
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+	tagGPSLatitudeRef   = 0x0001
+	tagGPSLatitude      = 0x0002
+	tagGPSLongitudeRef  = 0x0003
+	tagGPSLongitude     = 0x0004
+)
+
+var (
+	errNoExif = errors.New("no exif data")
+	// errMalformedImage 段或数据块长度异常，无法可靠定位元数据
+	errMalformedImage = errors.New("malformed image structure")
+)
+
+// ExtractExif 从 JPEG/PNG/WebP 中提取 EXIF 字段，不含 EXIF 时返回 nil
+func ExtractExif(data []byte) *ExifInfo {
+	raw := findExifPayload(data)
+	if raw == nil {
+		return nil
+	}
+	info, err := parseTIFF(raw)
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+// findExifPayload 定位 TIFF 格式的 EXIF 数据
+func findExifPayload(data []byte) []byte {
+	switch DetectImageType(data) {
+	case "image/jpeg":
+		var payload []byte
+		walkJPEGSegments(data, func(marker byte, seg []byte) bool {
+			if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+				payload = seg[6:]
+				return false
+			}
+			return true
+		})
+		return payload
+	case "image/png":
+		var payload []byte
+		walkPNGChunks(data, func(typ string, chunk []byte) bool {
+			if typ == "eXIf" {
+				payload = chunk
+				return false
+			}
+			return true
+		})
+		return payload
+	case "image/webp":
+		var payload []byte
+		walkWebPChunks(data, func(fourcc string, chunk []byte) bool {
+			if fourcc == "EXIF" {
+				payload = bytes.TrimPrefix(chunk, []byte("Exif\x00\x00"))
+				return false
+			}
+			return true
+		})
+		return payload
+	}
+	return nil
+}
+
+// tiffReader 按 TIFF 头声明的字节序读取 IFD
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+type ifdEntry struct {
+	tag    uint16
+	typ    uint16
+	count  uint32
+	offset []byte // 值或偏移（4 字节）
+}
+
+func parseTIFF(data []byte) (*ExifInfo, error) {
+	if len(data) < 8 {
+		return nil, errNoExif
+	}
+	r := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return nil, errNoExif
+	}
+
+	info := &ExifInfo{}
+	entries := r.readIFD(r.order.Uint32(data[4:8]))
+	for _, e := range entries {
+		switch e.tag {
+		case tagOrientation:
+			info.Orientation = int(r.order.Uint16(e.offset[:2]))
+		case tagMake, tagModel:
+			if s := r.asciiValue(e); s != "" {
+				if info.Camera != "" && !strings.HasPrefix(s, info.Camera) {
+					info.Camera += " " + s
+				} else {
+					info.Camera = s
+				}
+			}
+		case tagExifIFD:
+			for _, se := range r.readIFD(r.order.Uint32(e.offset)) {
+				if se.tag == tagDateTimeOriginal {
+					if t, err := time.ParseInLocation("2006:01:02 15:04:05", r.asciiValue(se), time.Local); err == nil {
+						info.TakenAt = &t
+					}
+				}
+			}
+		case tagGPSIFD:
+			r.readGPS(r.order.Uint32(e.offset), info)
+		}
+	}
+	return info, nil
+}
+
+func (r *tiffReader) readIFD(offset uint32) []ifdEntry {
+	if int(offset)+2 > len(r.data) {
+		return nil
+	}
+	n := int(r.order.Uint16(r.data[offset:]))
+	var entries []ifdEntry
+	for i := 0; i < n; i++ {
+		p := int(offset) + 2 + i*12
+		if p+12 > len(r.data) {
+			break
+		}
+		entries = append(entries, ifdEntry{
+			tag:    r.order.Uint16(r.data[p:]),
+			typ:    r.order.Uint16(r.data[p+2:]),
+			count:  r.order.Uint32(r.data[p+4:]),
+			offset: r.data[p+8 : p+12],
+		})
+	}
+	return entries
+}
+
+// value 返回条目的原始值字节，长度不超过 4 时值内联在条目中
+func (r *tiffReader) value(e ifdEntry, size int) []byte {
+	total := size * int(e.count)
+	if total <= 4 {
+		return e.offset[:total]
+	}
+	off := int(r.order.Uint32(e.offset))
+	if off < 0 || off+total > len(r.data) {
+		return nil
+	}
+	return r.data[off : off+total]
+}
+
+func (r *tiffReader) asciiValue(e ifdEntry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(r.value(e, 1)), "\x00"))
+}
+
+// rationals 读取 RATIONAL（类型 5）数组
+func (r *tiffReader) rationals(e ifdEntry) []float64 {
+	if e.typ != 5 {
+		return nil
+	}
+	raw := r.value(e, 8)
+	var out []float64
+	for i := 0; i+8 <= len(raw); i += 8 {
+		num := r.order.Uint32(raw[i:])
+		den := r.order.Uint32(raw[i+4:])
+		if den == 0 {
+			return nil
+		}
+		out = append(out, float64(num)/float64(den))
+	}
+	return out
+}
+
+func (r *tiffReader) readGPS(offset uint32, info *ExifInfo) {
+	var latRef, lonRef string
+	var lat, lon []float64
+	for _, e := range r.readIFD(offset) {
+		switch e.tag {
+		case tagGPSLatitudeRef:
+			latRef = r.asciiValue(e)
+		case tagGPSLatitude:
+			lat = r.rationals(e)
+		case tagGPSLongitudeRef:
+			lonRef = r.asciiValue(e)
+		case tagGPSLongitude:
+			lon = r.rationals(e)
+		}
+	}
+	if len(lat) == 3 && len(lon) == 3 {
+		la := lat[0] + lat[1]/60 + lat[2]/3600
+		lo := lon[0] + lon[1]/60 + lon[2]/3600
+		if latRef == "S" {
+			la = -la
+		}
+		if lonRef == "W" {
+			lo = -lo
+		}
+		info.GPSLatitude = &la
+		info.GPSLongitude = &lo
+	}
+}
+
+// StripMetadata 无损移除 EXIF/XMP/IPTC/注释等元数据，保留像素数据与 ICC 色彩配置。
+// 结构异常时返回错误而不是原始数据，调用方不能把未清除的字节当作结果保存
+func StripMetadata(data []byte) ([]byte, error) {
+	switch DetectImageType(data) {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	}
+	return data, nil
+}
+
+// resetOrientation 返回 IFD0 中 Orientation 置为 1 的 TIFF 数据副本，其余字段不变
+func resetOrientation(tiff []byte) []byte {
+	out := append([]byte(nil), tiff...)
+	if len(out) < 8 {
+		return out
+	}
+	r := &tiffReader{data: out}
+	switch string(out[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return out
+	}
+	for _, e := range r.readIFD(r.order.Uint32(out[4:8])) {
+		// 条目值内联，e.offset 指向 out 本身
+		if e.tag == tagOrientation && e.typ == 3 {
+			r.order.PutUint16(e.offset[:2], 1)
+		}
+	}
+	return out
+}
+
+// copyMetadata 把 src 中的元数据（EXIF、XMP、IPTC、ICC、文本等）写入由像素重新编码得到的 dst，
+// EXIF Orientation 置为 1；dst 须为本服务编码器的输出，本身不含元数据
+func copyMetadata(dst, src []byte, width, height int) ([]byte, error) {
+	switch DetectImageType(src) {
+	case "image/jpeg":
+		return copyJPEGMetadata(dst, src)
+	case "image/png":
+		return copyPNGMetadata(dst, src)
+	case "image/webp":
+		return copyWebPMetadata(dst, src, width, height)
+	}
+	return dst, nil
+}
+
+// walkJPEGSegments 遍历 SOS 之前的 JPEG 段，fn 返回 false 时停止
+func walkJPEGSegments(data []byte, fn func(marker byte, seg []byte) bool) {
+	p := 2
+	for p+4 <= len(data) && data[p] == 0xFF {
+		marker := data[p+1]
+		if marker == 0xDA {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(data[p+2:]))
+		if length < 2 || p+2+length > len(data) {
+			return
+		}
+		if !fn(marker, data[p+4:p+2+length]) {
+			return
+		}
+		p += 2 + length
+	}
+}
+
+func stripJPEG(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	p := 2
+	for {
+		// 未到达 SOS 前出现截断或非标记字节，剩余部分可能仍含元数据
+		if p+4 > len(data) || data[p] != 0xFF {
+			return nil, errMalformedImage
+		}
+		marker := data[p+1]
+		if marker == 0xDA {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[p+2:]))
+		if length < 2 || p+2+length > len(data) {
+			return nil, errMalformedImage
+		}
+		// APP1（EXIF/XMP）、APP13（IPTC）、COM（注释）
+		if marker != 0xE1 && marker != 0xED && marker != 0xFE {
+			out = append(out, data[p:p+2+length]...)
+		}
+		p += 2 + length
+	}
+	return append(out, data[p:]...), nil
+}
+
+func copyJPEGMetadata(dst, src []byte) ([]byte, error) {
+	if DetectImageType(dst) != "image/jpeg" {
+		return nil, errMalformedImage
+	}
+	var meta []byte
+	walkJPEGSegments(src, func(marker byte, seg []byte) bool {
+		// APP0-APP15（JFIF、EXIF、XMP、ICC、IPTC 等）与 COM
+		if (marker >= 0xE0 && marker <= 0xEF) || marker == 0xFE {
+			if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+				seg = append([]byte("Exif\x00\x00"), resetOrientation(seg[6:])...)
+			}
+			meta = append(meta, 0xFF, marker, byte((len(seg)+2)>>8), byte(len(seg)+2))
+			meta = append(meta, seg...)
+		}
+		return true
+	})
+	out := make([]byte, 0, len(dst)+len(meta))
+	out = append(out, dst[:2]...)
+	out = append(out, meta...)
+	return append(out, dst[2:]...), nil
+}
+
+// walkPNGChunks 遍历 PNG 数据块，fn 返回 false 时停止
+func walkPNGChunks(data []byte, fn func(typ string, chunk []byte) bool) {
+	p := 8
+	for p+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[p:]))
+		if length < 0 || p+12+length > len(data) {
+			return
+		}
+		if !fn(string(data[p+4:p+8]), data[p+8:p+8+length]) {
+			return
+		}
+		p += 12 + length
+	}
+}
+
+func stripPNG(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:8]...)
+	p := 8
+	for p+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[p:]))
+		if length < 0 || p+12+length > len(data) {
+			return nil, errMalformedImage
+		}
+		switch string(data[p+4 : p+8]) {
+		case "tEXt", "zTXt", "iTXt", "eXIf", "tIME":
+		default:
+			out = append(out, data[p:p+12+length]...)
+		}
+		p += 12 + length
+	}
+	return out, nil
+}
+
+func copyPNGMetadata(dst, src []byte) ([]byte, error) {
+	// 编码器输出以 IHDR 开头，辅助数据块插入其后（iCCP 等须位于 IDAT 之前）
+	if DetectImageType(dst) != "image/png" || len(dst) < 33 || string(dst[12:16]) != "IHDR" {
+		return nil, errMalformedImage
+	}
+	var meta []byte
+	walkPNGChunks(src, func(typ string, chunk []byte) bool {
+		switch typ {
+		case "iCCP", "sRGB", "gAMA", "cHRM", "pHYs", "tEXt", "zTXt", "iTXt", "eXIf", "tIME":
+			if typ == "eXIf" {
+				chunk = resetOrientation(chunk)
+			}
+			meta = appendPNGChunk(meta, typ, chunk)
+		}
+		return true
+	})
+	out := make([]byte, 0, len(dst)+len(meta))
+	out = append(out, dst[:33]...)
+	out = append(out, meta...)
+	return append(out, dst[33:]...), nil
+}
+
+func appendPNGChunk(out []byte, typ string, chunk []byte) []byte {
+	out = binary.BigEndian.AppendUint32(out, uint32(len(chunk)))
+	start := len(out)
+	out = append(out, typ...)
+	out = append(out, chunk...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out[start:]))
+}
+
+// walkWebPChunks 遍历 RIFF/WEBP 数据块，fn 返回 false 时停止
+func walkWebPChunks(data []byte, fn func(fourcc string, chunk []byte) bool) {
+	p := 12
+	for p+8 <= len(data) {
+		size := int(binary.LittleEndian.Uint32(data[p+4:]))
+		if size < 0 || p+8+size > len(data) {
+			return
+		}
+		if !fn(string(data[p:p+4]), data[p+8:p+8+size]) {
+			return
+		}
+		p += 8 + size + size%2
+	}
+}
+
+func stripWebP(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+	p := 12
+	for p+8 <= len(data) {
+		size := int(binary.LittleEndian.Uint32(data[p+4:]))
+		end := p + 8 + size + size%2
+		if size < 0 || end > len(data) {
+			return nil, errMalformedImage
+		}
+		switch string(data[p : p+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[p:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04 // 清除 EXIF / XMP 标志位
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[p:end]...)
+		}
+		p = end
+	}
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
+
+// VP8X 标志位
+const (
+	webpFlagICC   = 0x20
+	webpFlagAlpha = 0x10
+	webpFlagEXIF  = 0x08
+	webpFlagXMP   = 0x04
+)
+
+func copyWebPMetadata(dst, src []byte, width, height int) ([]byte, error) {
+	if DetectImageType(dst) != "image/webp" {
+		return nil, errMalformedImage
+	}
+	var iccp, exif, xmp []byte
+	walkWebPChunks(src, func(fourcc string, chunk []byte) bool {
+		switch fourcc {
+		case "ICCP":
+			iccp = chunk
+		case "EXIF":
+			if bytes.HasPrefix(chunk, []byte("Exif\x00\x00")) {
+				exif = append([]byte("Exif\x00\x00"), resetOrientation(chunk[6:])...)
+			} else {
+				exif = resetOrientation(chunk)
+			}
+		case "XMP ":
+			xmp = chunk
+		}
+		return true
+	})
+	if iccp == nil && exif == nil && xmp == nil {
+		return dst, nil
+	}
+
+	// 带元数据的 WebP 须使用扩展格式：VP8X、ICCP、图像数据、EXIF、XMP 依次排列
+	var flags byte
+	var body []byte
+	walkWebPChunks(dst, func(fourcc string, chunk []byte) bool {
+		switch fourcc {
+		case "VP8X":
+			if len(chunk) > 0 {
+				flags |= chunk[0] & webpFlagAlpha
+			}
+			return true
+		case "VP8L":
+			// VP8L 头：签名 1 字节，随后 14+14 位宽高、1 位 alpha_is_used
+			if len(chunk) >= 5 && binary.LittleEndian.Uint32(chunk[1:5])>>28&1 == 1 {
+				flags |= webpFlagAlpha
+			}
+		case "ALPH":
+			flags |= webpFlagAlpha
+		}
+		body = appendWebPChunk(body, fourcc, chunk)
+		return true
+	})
+	if body == nil {
+		return nil, errMalformedImage
+	}
+
+	vp8x := make([]byte, 10)
+	vp8x[4] = byte(width - 1)
+	vp8x[5] = byte((width - 1) >> 8)
+	vp8x[6] = byte((width - 1) >> 16)
+	vp8x[7] = byte(height - 1)
+	vp8x[8] = byte((height - 1) >> 8)
+	vp8x[9] = byte((height - 1) >> 16)
+
+	out := append([]byte(nil), dst[:12]...)
+	chunks := []byte(nil)
+	if iccp != nil {
+		flags |= webpFlagICC
+		chunks = appendWebPChunk(chunks, "ICCP", iccp)
+	}
+	chunks = append(chunks, body...)
+	if exif != nil {
+		flags |= webpFlagEXIF
+		chunks = appendWebPChunk(chunks, "EXIF", exif)
+	}
+	if xmp != nil {
+		flags |= webpFlagXMP
+		chunks = appendWebPChunk(chunks, "XMP ", xmp)
+	}
+	vp8x[0] = flags
+	out = appendWebPChunk(out, "VP8X", vp8x)
+	out = append(out, chunks...)
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
+
+func appendWebPChunk(out []byte, fourcc string, chunk []byte) []byte {
+	out = append(out, fourcc...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(chunk)))
+	out = append(out, chunk...)
+	if len(chunk)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
@@ -0,0 +1,420 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"testing"
+	"time"
+
+	"image-host/config"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/vp8l"
+)
+
+// tiffEntry 构造测试 EXIF 用的 IFD 条目，data 为按小端序编码的值
+type tiffEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+func shortEntry(tag, v uint16) tiffEntry {
+	return tiffEntry{tag, 3, 1, binary.LittleEndian.AppendUint16(nil, v)}
+}
+
+func longEntry(tag uint16, v uint32) tiffEntry {
+	return tiffEntry{tag, 4, 1, binary.LittleEndian.AppendUint32(nil, v)}
+}
+
+func asciiEntry(tag uint16, s string) tiffEntry {
+	return tiffEntry{tag, 2, uint32(len(s) + 1), append([]byte(s), 0)}
+}
+
+func rationalEntry(tag uint16, vals ...uint32) tiffEntry {
+	var data []byte
+	for _, v := range vals {
+		data = binary.LittleEndian.AppendUint32(data, v)
+		data = binary.LittleEndian.AppendUint32(data, 1)
+	}
+	return tiffEntry{tag, 5, uint32(len(vals)), data}
+}
+
+// appendIFD 在 buf 末尾写入 IFD，超过 4 字节的值紧随其后；返回 IFD 偏移
+func appendIFD(buf *[]byte, entries []tiffEntry) uint32 {
+	off := len(*buf)
+	dataOff := off + 2 + 12*len(entries) + 4
+	table := binary.LittleEndian.AppendUint16(nil, uint16(len(entries)))
+	var data []byte
+	for _, e := range entries {
+		table = binary.LittleEndian.AppendUint16(table, e.tag)
+		table = binary.LittleEndian.AppendUint16(table, e.typ)
+		table = binary.LittleEndian.AppendUint32(table, e.count)
+		if len(e.data) <= 4 {
+			table = append(table, e.data...)
+			table = append(table, make([]byte, 4-len(e.data))...)
+			continue
+		}
+		table = binary.LittleEndian.AppendUint32(table, uint32(dataOff+len(data)))
+		data = append(data, e.data...)
+		if len(data)%2 == 1 {
+			data = append(data, 0)
+		}
+	}
+	table = binary.LittleEndian.AppendUint32(table, 0)
+	*buf = append(append(*buf, table...), data...)
+	return uint32(off)
+}
+
+// testTIFF 含方向、相机、拍摄时间与 GPS（35°40'30"N 139°45'0"W）的 EXIF
+func testTIFF(orientation uint16) []byte {
+	buf := []byte("II*\x00\x00\x00\x00\x00")
+	exifIFD := appendIFD(&buf, []tiffEntry{asciiEntry(tagDateTimeOriginal, "2024:05:01 10:20:30")})
+	gpsIFD := appendIFD(&buf, []tiffEntry{
+		asciiEntry(tagGPSLatitudeRef, "N"),
+		rationalEntry(tagGPSLatitude, 35, 40, 30),
+		asciiEntry(tagGPSLongitudeRef, "W"),
+		rationalEntry(tagGPSLongitude, 139, 45, 0),
+	})
+	ifd0 := appendIFD(&buf, []tiffEntry{
+		asciiEntry(tagMake, "Apple"),
+		asciiEntry(tagModel, "iPhone 15"),
+		shortEntry(tagOrientation, orientation),
+		longEntry(tagExifIFD, exifIFD),
+		longEntry(tagGPSIFD, gpsIFD),
+	})
+	binary.LittleEndian.PutUint32(buf[4:8], ifd0)
+	return buf
+}
+
+// testPixels 4x2 的非对称图像，便于检查旋转后的尺寸
+func testPixels() image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 60), uint8(y * 120), 80, 255})
+		}
+	}
+	return img
+}
+
+func jpegSegment(marker byte, payload string) []byte {
+	return append([]byte{0xFF, marker, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}, payload...)
+}
+
+const (
+	testXMP  = "http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>secret</x:xmpmeta>"
+	testICC  = "ICC_PROFILE\x00\x01\x01profile-bytes"
+	testIPTC = "Photoshop 3.0\x008BIM-iptc"
+)
+
+// testJPEG JPEG：APP1 EXIF、APP1 XMP、APP2 ICC、APP13 IPTC 与 COM 注释
+func testJPEG(t *testing.T, orientation uint16) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testPixels(), nil); err != nil {
+		t.Fatal(err)
+	}
+	enc := buf.Bytes()
+	out := append([]byte(nil), enc[:2]...)
+	out = append(out, jpegSegment(0xE1, "Exif\x00\x00"+string(testTIFF(orientation)))...)
+	out = append(out, jpegSegment(0xE1, testXMP)...)
+	out = append(out, jpegSegment(0xE2, testICC)...)
+	out = append(out, jpegSegment(0xED, testIPTC)...)
+	out = append(out, jpegSegment(0xFE, "shot by someone")...)
+	return append(out, enc[2:]...)
+}
+
+// testPNG PNG：IHDR 之后依次为 iCCP、eXIf、tEXt
+func testPNG(t *testing.T, orientation uint16) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testPixels()); err != nil {
+		t.Fatal(err)
+	}
+	enc := buf.Bytes()
+	out := append([]byte(nil), enc[:33]...)
+	out = appendPNGChunk(out, "iCCP", []byte("icc\x00\x00compressed"))
+	out = appendPNGChunk(out, "eXIf", testTIFF(orientation))
+	out = appendPNGChunk(out, "tEXt", []byte("Comment\x00secret"))
+	return append(out, enc[33:]...)
+}
+
+// testWebP 扩展格式 WebP：VP8X（ICC|EXIF|XMP）、ICCP、VP8L、EXIF、XMP
+func testWebP(t *testing.T, orientation uint16) []byte {
+	var buf bytes.Buffer
+	if err := nativewebp.Encode(&buf, testPixels(), nil); err != nil {
+		t.Fatal(err)
+	}
+	var body []byte
+	walkWebPChunks(buf.Bytes(), func(fourcc string, chunk []byte) bool {
+		body = appendWebPChunk(body, fourcc, chunk)
+		return true
+	})
+	out := []byte("RIFF\x00\x00\x00\x00WEBP")
+	out = appendWebPChunk(out, "VP8X", []byte{webpFlagICC | webpFlagEXIF | webpFlagXMP, 0, 0, 0, 3, 0, 0, 1, 0, 0})
+	out = appendWebPChunk(out, "ICCP", []byte(testICC))
+	out = append(out, body...)
+	out = appendWebPChunk(out, "EXIF", append([]byte("Exif\x00\x00"), testTIFF(orientation)...))
+	out = appendWebPChunk(out, "XMP ", []byte("<x:xmpmeta>secret</x:xmpmeta>"))
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out
+}
+
+func webpChunks(data []byte) map[string][]byte {
+	chunks := map[string][]byte{}
+	walkWebPChunks(data, func(fourcc string, chunk []byte) bool {
+		chunks[fourcc] = chunk
+		return true
+	})
+	return chunks
+}
+
+func pngChunks(data []byte) map[string][]byte {
+	chunks := map[string][]byte{}
+	walkPNGChunks(data, func(typ string, chunk []byte) bool {
+		chunks[typ] = chunk
+		return true
+	})
+	return chunks
+}
+
+// decodeSize 解码并返回尺寸。内置 WebP 解码器不支持带元数据标志的 VP8X，此时直接解码 VP8L 数据块
+func decodeSize(t *testing.T, data []byte) (int, int) {
+	if chunks := webpChunks(data); DetectImageType(data) == "image/webp" && chunks["VP8X"] != nil {
+		img, err := vp8l.Decode(bytes.NewReader(chunks["VP8L"]))
+		if err != nil {
+			t.Fatalf("decode VP8L: %v", err)
+		}
+		return img.Bounds().Dx(), img.Bounds().Dy()
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return img.Bounds().Dx(), img.Bounds().Dy()
+}
+
+func TestExtractExif(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"jpeg": testJPEG(t, 6),
+		"png":  testPNG(t, 6),
+		"webp": testWebP(t, 6),
+	} {
+		info := ExtractExif(data)
+		if info == nil {
+			t.Fatalf("%s: no exif", name)
+		}
+		if info.Orientation != 6 || info.Camera != "Apple iPhone 15" {
+			t.Errorf("%s: orientation %d camera %q", name, info.Orientation, info.Camera)
+		}
+		want := time.Date(2024, 5, 1, 10, 20, 30, 0, time.Local)
+		if info.TakenAt == nil || !info.TakenAt.Equal(want) {
+			t.Errorf("%s: taken at %v", name, info.TakenAt)
+		}
+		if info.GPSLatitude == nil || info.GPSLongitude == nil ||
+			math.Abs(*info.GPSLatitude-35.675) > 1e-9 || math.Abs(*info.GPSLongitude+139.75) > 1e-9 {
+			t.Errorf("%s: gps %v %v", name, info.GPSLatitude, info.GPSLongitude)
+		}
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, testPixels())
+	if info := ExtractExif(buf.Bytes()); info != nil {
+		t.Fatalf("png without exif: %+v", info)
+	}
+}
+
+func TestStripJPEG(t *testing.T) {
+	out, err := StripMetadata(testJPEG(t, 1))
+	if err != nil {
+		t.Fatalf("StripMetadata: %v", err)
+	}
+	markers := map[byte]int{}
+	walkJPEGSegments(out, func(marker byte, seg []byte) bool {
+		markers[marker]++
+		return true
+	})
+	if markers[0xE1] != 0 || markers[0xED] != 0 || markers[0xFE] != 0 {
+		t.Fatalf("metadata segments left: %v", markers)
+	}
+	if markers[0xE2] != 1 || !bytes.Contains(out, []byte(testICC)) {
+		t.Fatalf("ICC profile dropped: %v", markers)
+	}
+	if ExtractExif(out) != nil || bytes.Contains(out, []byte("secret")) || bytes.Contains(out, []byte("8BIM")) {
+		t.Fatal("metadata still present")
+	}
+	if w, h := decodeSize(t, out); w != 4 || h != 2 {
+		t.Fatalf("decoded %dx%d", w, h)
+	}
+}
+
+func TestStripPNG(t *testing.T) {
+	out, err := StripMetadata(testPNG(t, 1))
+	if err != nil {
+		t.Fatalf("StripMetadata: %v", err)
+	}
+	chunks := pngChunks(out)
+	if _, ok := chunks["eXIf"]; ok {
+		t.Fatal("eXIf left")
+	}
+	if _, ok := chunks["tEXt"]; ok {
+		t.Fatal("tEXt left")
+	}
+	if _, ok := chunks["iCCP"]; !ok {
+		t.Fatal("iCCP dropped")
+	}
+	if w, h := decodeSize(t, out); w != 4 || h != 2 {
+		t.Fatalf("decoded %dx%d", w, h)
+	}
+}
+
+func TestStripWebP(t *testing.T) {
+	out, err := StripMetadata(testWebP(t, 1))
+	if err != nil {
+		t.Fatalf("StripMetadata: %v", err)
+	}
+	chunks := webpChunks(out)
+	if _, ok := chunks["EXIF"]; ok {
+		t.Fatal("EXIF left")
+	}
+	if _, ok := chunks["XMP "]; ok {
+		t.Fatal("XMP left")
+	}
+	if _, ok := chunks["ICCP"]; !ok {
+		t.Fatal("ICCP dropped")
+	}
+	if flags := chunks["VP8X"][0]; flags&(webpFlagEXIF|webpFlagXMP) != 0 || flags&webpFlagICC == 0 {
+		t.Fatalf("VP8X flags = %#x", flags)
+	}
+	if size := binary.LittleEndian.Uint32(out[4:8]); int(size) != len(out)-8 {
+		t.Fatalf("RIFF size %d, file %d", size, len(out))
+	}
+	if w, h := decodeSize(t, out); w != 4 || h != 2 {
+		t.Fatalf("decoded %dx%d", w, h)
+	}
+}
+
+func TestStripMetadataMalformed(t *testing.T) {
+	jpg := testJPEG(t, 1)
+	pngData := testPNG(t, 1)
+	webp := testWebP(t, 1)
+
+	badJPEGLength := append([]byte(nil), jpg...)
+	badJPEGLength[4], badJPEGLength[5] = 0xFF, 0xFF // 首个 APP1 长度越界
+	badPNGLength := append([]byte(nil), pngData...)
+	binary.BigEndian.PutUint32(badPNGLength[33:], 1<<30) // iCCP 长度越界
+	badWebPSize := append([]byte(nil), webp...)
+	binary.LittleEndian.PutUint32(badWebPSize[16:], 1<<30) // VP8X 大小越界
+
+	cases := map[string][]byte{
+		"jpeg truncated in APP1":    jpg[:20],
+		"jpeg truncated before SOS": jpg[:2+len(jpegSegment(0xE1, "Exif\x00\x00"+string(testTIFF(1))))],
+		"jpeg bad segment length":   badJPEGLength,
+		"jpeg garbage before APP1":  append(append(append([]byte{0xFF, 0xD8}, jpegSegment(0xFE, "c")...), 0x00), jpg[2:]...),
+		"png truncated in eXIf":     pngData[:len(pngData)-len(pngData)/2],
+		"png bad chunk length":      badPNGLength,
+		"webp truncated in EXIF":    webp[:len(webp)-20],
+		"webp bad chunk size":       badWebPSize,
+	}
+	for name, data := range cases {
+		out, err := StripMetadata(data)
+		if !errors.Is(err, errMalformedImage) || out != nil {
+			t.Errorf("%s: err = %v, out = %d bytes", name, err, len(out))
+		}
+	}
+}
+
+func TestApplyMetadataPolicy(t *testing.T) {
+	old := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = old })
+	s := &ImageService{}
+
+	for _, format := range []string{"jpeg", "png", "webp"} {
+		var data []byte
+		switch format {
+		case "jpeg":
+			data = testJPEG(t, 6)
+		case "png":
+			data = testPNG(t, 6)
+		case "webp":
+			data = testWebP(t, 6)
+		}
+		oriented := applyOrientation(testPixels(), 6)
+
+		config.AppConfig = &config.Config{MetadataPolicy: "keep"}
+		out, err := s.applyMetadataPolicy(data, oriented, format, 6)
+		if err != nil || !bytes.Equal(out, data) {
+			t.Fatalf("%s keep: err = %v, changed = %v", format, err, !bytes.Equal(out, data))
+		}
+
+		// strip：旋正像素、保持原格式，不保留任何元数据
+		config.AppConfig = &config.Config{MetadataPolicy: "strip"}
+		out, err = s.applyMetadataPolicy(data, oriented, format, 6)
+		if err != nil {
+			t.Fatalf("%s strip: %v", format, err)
+		}
+		if DetectImageType(out) != "image/"+format {
+			t.Fatalf("%s strip: stored as %s", format, DetectImageType(out))
+		}
+		if w, h := decodeSize(t, out); w != 2 || h != 4 {
+			t.Fatalf("%s strip: decoded %dx%d, want 2x4", format, w, h)
+		}
+		if ExtractExif(out) != nil || bytes.Contains(out, []byte("secret")) {
+			t.Fatalf("%s strip: metadata left", format)
+		}
+
+		// orient：旋正像素，其余元数据保留且 Orientation 置为 1
+		config.AppConfig = &config.Config{MetadataPolicy: "orient"}
+		out, err = s.applyMetadataPolicy(data, oriented, format, 6)
+		if err != nil {
+			t.Fatalf("%s orient: %v", format, err)
+		}
+		if DetectImageType(out) != "image/"+format {
+			t.Fatalf("%s orient: stored as %s", format, DetectImageType(out))
+		}
+		if w, h := decodeSize(t, out); w != 2 || h != 4 {
+			t.Fatalf("%s orient: decoded %dx%d, want 2x4", format, w, h)
+		}
+		info := ExtractExif(out)
+		if info == nil || info.Orientation != 1 || info.GPSLatitude == nil || info.Camera != "Apple iPhone 15" {
+			t.Fatalf("%s orient: exif %+v", format, info)
+		}
+		if !bytes.Contains(out, []byte("secret")) {
+			t.Fatalf("%s orient: XMP/text metadata dropped", format)
+		}
+		if format == "webp" {
+			chunks := webpChunks(out)
+			if flags := chunks["VP8X"][0]; flags&(webpFlagICC|webpFlagEXIF|webpFlagXMP) != webpFlagICC|webpFlagEXIF|webpFlagXMP {
+				t.Fatalf("webp orient: VP8X flags %#x", flags)
+			}
+		}
+	}
+
+	// 不需要旋转时 orient 原样保存
+	config.AppConfig = &config.Config{MetadataPolicy: "orient"}
+	data := testJPEG(t, 1)
+	if out, err := s.applyMetadataPolicy(data, testPixels(), "jpeg", 1); err != nil || !bytes.Equal(out, data) {
+		t.Fatalf("orient without rotation: err = %v", err)
+	}
+
+	// 结构异常无法无损移除时重新编码像素，不保存原始字节
+	config.AppConfig = &config.Config{MetadataPolicy: "strip"}
+	bad := append([]byte(nil), testJPEG(t, 1)...)
+	bad[4], bad[5] = 0xFF, 0xFF
+	out, err := s.applyMetadataPolicy(bad, testPixels(), "jpeg", 1)
+	if err != nil {
+		t.Fatalf("strip malformed: %v", err)
+	}
+	if bytes.Contains(out, []byte("Exif")) || bytes.Contains(out, []byte("secret")) {
+		t.Fatal("strip malformed: metadata left")
+	}
+	if w, h := decodeSize(t, out); w != 4 || h != 2 {
+		t.Fatalf("strip malformed: decoded %dx%d", w, h)
+	}
+}
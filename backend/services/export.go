@@ -0,0 +1,114 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"image-host/models"
+)
+
+type ExportService struct{}
+
+var Export = &ExportService{}
+
+// ExportManifest 导出包中的 manifest.json
+type ExportManifest struct {
+	ExportedAt time.Time     `json:"exported_at"`
+	ExportedBy string        `json:"exported_by"`
+	Count      int           `json:"count"`
+	Images     []ExportEntry `json:"images"`
+}
+
+// ExportEntry 单张图片的元数据及其在压缩包中的路径；读取失败时 File 为空并记录 Error
+type ExportEntry struct {
+	models.Image
+	File  string `json:"file,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// WriteZip 将图片逐个从存储读取并写入 ZIP（不压缩，图片本身已压缩），最后写入 manifest.json；
+// 单个文件读取失败时记录在 manifest 中并继续，写入 w 失败时返回错误
+func (s *ExportService) WriteZip(w io.Writer, images []models.Image, exportedBy string) error {
+	zw := zip.NewWriter(w)
+	manifest := ExportManifest{
+		ExportedAt: time.Now(),
+		ExportedBy: exportedBy,
+		Count:      len(images),
+		Images:     make([]ExportEntry, 0, len(images)),
+	}
+
+	used := map[string]bool{}
+	for i := range images {
+		img := images[i]
+		entry := ExportEntry{Image: img}
+		name := exportFileName(&img, used)
+		written, err := s.writeEntry(zw, &img, name)
+		switch {
+		case err == nil:
+			entry.File = name
+		case written:
+			// 条目已开始写入，输出流已不可恢复
+			return err
+		default:
+			entry.Error = err.Error()
+		}
+		manifest.Images = append(manifest.Images, entry)
+	}
+
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: manifest.ExportedAt})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeEntry 写入单个文件；written 表示错误发生时条目是否已写入压缩包
+func (s *ExportService) writeEntry(zw *zip.Writer, img *models.Image, name string) (written bool, err error) {
+	rc, err := R2.OpenFile(img.R2Key)
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: img.CreatedAt})
+	if err != nil {
+		return true, err
+	}
+	if _, err := io.Copy(fw, rc); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// exportFileName 以原始文件名命名（去除路径与控制字符），重名时追加序号
+func exportFileName(img *models.Image, used map[string]bool) string {
+	name := strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '/' || r == '\\' || r == ':' {
+			return '_'
+		}
+		return r
+	}, path.Base(img.OriginalName))
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		name = img.UUID + path.Ext(img.R2Key)
+	}
+
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := "images/" + name
+	for n := 2; used[strings.ToLower(candidate)]; n++ {
+		candidate = fmt.Sprintf("images/%s (%d)%s", base, n, ext)
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
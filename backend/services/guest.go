@@ -1,18 +1,49 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"strconv"
+	"strings"
 	"time"
 
+	"image-host/config"
 	"image-host/database"
 	"image-host/models"
+
+	"gorm.io/gorm"
 )
 
 type GuestService struct{}
 
 var Guest = &GuestService{}
 
+var (
+	ErrGuestCodeInvalid     = errors.New("invalid code")
+	ErrGuestCodeExpired     = errors.New("code expired")
+	ErrGuestCodeDisabled    = errors.New("code disabled")
+	ErrGuestLoginLimit      = errors.New("login limit reached")
+	ErrGuestUploadLimit     = errors.New("upload limit reached")
+	ErrGuestTypesNotAllowed = errors.New("allowed types must be a subset of ALLOWED_TYPES")
+)
+
+// GuestCodeOptions 游客码的标签与使用限制
+type GuestCodeOptions struct {
+	Label        string
+	MaxLogins    int
+	MaxUploads   int
+	AllowedTypes string
+	MaxFileSize  int64
+}
+
+// GuestCodeUsage 游客码的图片用量
+type GuestCodeUsage struct {
+	Images int64 `json:"images"`
+	Bytes  int64 `json:"bytes"`
+}
+
 func randomCode(n int) string {
 	const letters = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
 	b := make([]byte, n)
@@ -23,12 +54,18 @@ func randomCode(n int) string {
 }
 
 // GenerateCode 生成游客码
-func (s *GuestService) GenerateCode(creator string, expiresAt *time.Time) (*models.GuestCode, error) {
+func (s *GuestService) GenerateCode(creator string, expiresAt *time.Time, opts GuestCodeOptions, limits models.QuotaLimits) (*models.GuestCode, error) {
 	code := randomCode(10)
 	g := &models.GuestCode{
-		Code:      code,
-		ExpiresAt: expiresAt,
-		CreatedBy: creator,
+		Code:         code,
+		Label:        opts.Label,
+		ExpiresAt:    expiresAt,
+		CreatedBy:    creator,
+		MaxLogins:    opts.MaxLogins,
+		MaxUploads:   opts.MaxUploads,
+		AllowedTypes: opts.AllowedTypes,
+		MaxFileSize:  opts.MaxFileSize,
+		QuotaLimits:  limits,
 	}
 	if err := database.DB.Create(g).Error; err != nil {
 		return nil, err
@@ -36,7 +73,153 @@ func (s *GuestService) GenerateCode(creator string, expiresAt *time.Time) (*mode
 	return g, nil
 }
 
-// DeleteCodeAndImages 删除指定游客码及其图片
+// NormalizeAllowedTypes 规范化 MIME 类型列表，须为全局 ALLOWED_TYPES 的子集，返回逗号分隔形式
+func NormalizeAllowedTypes(list string) (string, error) {
+	var out []string
+	for _, t := range strings.Split(list, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		if !containsString(config.AppConfig.AllowedTypes, t) {
+			return "", ErrGuestTypesNotAllowed
+		}
+		if !containsString(out, t) {
+			out = append(out, t)
+		}
+	}
+	return strings.Join(out, ","), nil
+}
+
+func containsString(list []string, v string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Login 校验游客码并计入一次登录；登录次数在数据库中条件递增，并发登录不会超出上限
+func (s *GuestService) Login(code string) (*models.GuestCode, error) {
+	var gc models.GuestCode
+	if err := database.DB.Where("code = ?", code).First(&gc).Error; err != nil {
+		return nil, ErrGuestCodeInvalid
+	}
+	if err := checkUsable(&gc); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	res := database.DB.Model(&models.GuestCode{}).
+		Where("id = ? AND (max_logins = 0 OR login_count < max_logins)", gc.ID).
+		Updates(map[string]interface{}{"login_count": gorm.Expr("login_count + 1"), "last_used_at": now})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrGuestLoginLimit
+	}
+	gc.LoginCount++
+	gc.LastUsedAt = &now
+	return &gc, nil
+}
+
+// CodeFor 根据游客用户名 guest:<id> 取得可用的游客码
+func (s *GuestService) CodeFor(username string) (*models.GuestCode, error) {
+	id, ok := strings.CutPrefix(username, "guest:")
+	if !ok {
+		return nil, ErrGuestCodeInvalid
+	}
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, ErrGuestCodeInvalid
+	}
+	var gc models.GuestCode
+	if err := database.DB.First(&gc, n).Error; err != nil {
+		return nil, ErrGuestCodeInvalid
+	}
+	if err := checkUsable(&gc); err != nil {
+		return nil, err
+	}
+	return &gc, nil
+}
+
+func checkUsable(gc *models.GuestCode) error {
+	if gc.Disabled {
+		return ErrGuestCodeDisabled
+	}
+	if gc.ExpiresAt != nil && time.Now().After(*gc.ExpiresAt) {
+		return ErrGuestCodeExpired
+	}
+	return nil
+}
+
+// UploadLimits 游客码生效的 MIME 类型与单文件大小限制（未设置时沿用全局）
+func (s *GuestService) UploadLimits(gc *models.GuestCode) ([]string, int64) {
+	types := config.AppConfig.AllowedTypes
+	if gc.AllowedTypes != "" {
+		types = strings.Split(gc.AllowedTypes, ",")
+	}
+	maxSize := config.AppConfig.MaxFileSize
+	if gc.MaxFileSize > 0 && gc.MaxFileSize < maxSize {
+		maxSize = gc.MaxFileSize
+	}
+	return types, maxSize
+}
+
+// ReserveUpload 占用一次上传次数，达到上限时返回 ErrGuestUploadLimit；上传失败时应调用 ReleaseUpload
+func (s *GuestService) ReserveUpload(id uint) error {
+	res := database.DB.Model(&models.GuestCode{}).
+		Where("id = ? AND (max_uploads = 0 OR upload_count < max_uploads)", id).
+		Updates(map[string]interface{}{"upload_count": gorm.Expr("upload_count + 1"), "last_used_at": time.Now()})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrGuestUploadLimit
+	}
+	return nil
+}
+
+// ReleaseUpload 归还 ReserveUpload 占用的上传次数
+func (s *GuestService) ReleaseUpload(id uint) {
+	database.DB.Model(&models.GuestCode{}).
+		Where("id = ? AND upload_count > 0", id).
+		UpdateColumn("upload_count", gorm.Expr("upload_count - 1"))
+}
+
+// Usage 按游客码统计现存图片数量与字节数
+func (s *GuestService) Usage(ids []uint) (map[uint]GuestCodeUsage, error) {
+	out := make(map[uint]GuestCodeUsage, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	uploaders := make([]string, 0, len(ids))
+	for _, id := range ids {
+		uploaders = append(uploaders, fmt.Sprintf("guest:%d", id))
+	}
+	var rows []struct {
+		Uploader string
+		Images   int64
+		Bytes    int64
+	}
+	if err := database.DB.Model(&models.Image{}).
+		Select("uploader, COUNT(*) AS images, COALESCE(SUM(file_size), 0) AS bytes").
+		Where("uploader IN ?", uploaders).
+		Group("uploader").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		if n, err := strconv.ParseUint(strings.TrimPrefix(r.Uploader, "guest:"), 10, 64); err == nil {
+			out[uint(n)] = GuestCodeUsage{Images: r.Images, Bytes: r.Bytes}
+		}
+	}
+	return out, nil
+}
+
+// DeleteCodeAndImages 删除指定游客码，其图片移入回收站。游客码为软删除：不可再登录与上传，
+// 但记录保留，回收站中的图片恢复后仍能找到其上传者（可由管理员批量转移）
 func (s *GuestService) DeleteCodeAndImages(id string) error {
 	var gc models.GuestCode
 	if err := database.DB.Where("id = ?", id).First(&gc).Error; err != nil {
@@ -44,21 +227,16 @@ func (s *GuestService) DeleteCodeAndImages(id string) error {
 	}
 	uploader := fmt.Sprintf("guest:%d", gc.ID)
 
-	// 删除文件与记录
-	var images []models.Image
-	if err := database.DB.Where("uploader = ?", uploader).Find(&images).Error; err == nil {
-		for _, img := range images {
-			if img.R2Key != "" {
-				_ = R2.DeleteFile(img.R2Key)
-			}
-			_ = database.DB.Unscoped().Delete(&img).Error
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// 图片移入回收站，保留期内可由管理员恢复
+		if err := tx.Where("uploader = ?", uploader).Delete(&models.Image{}).Error; err != nil {
+			return err
 		}
-	}
-	// 删除游客码
-	return database.DB.Unscoped().Delete(&gc).Error
+		return tx.Delete(&gc).Error
+	})
 }
 
-// CleanupExpired 定时清理过期游客码与其图片
+// CleanupExpired 定时清理过期游客码，其图片移入回收站
 func (s *GuestService) CleanupExpired() {
 	now := time.Now()
 	var expired []models.GuestCode
@@ -72,10 +250,5 @@ func (s *GuestService) CleanupExpired() {
 
 // StartCleanupJob 每小时清理一次
 func (s *GuestService) StartCleanupJob() {
-	ticker := time.NewTicker(1 * time.Hour)
-	go func() {
-		for range ticker.C {
-			s.CleanupExpired()
-		}
-	}()
+	Jobs.Every("guest-cleanup", time.Hour, func(context.Context) { s.CleanupExpired() })
 }
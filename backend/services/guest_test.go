@@ -0,0 +1,59 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"image-host/models"
+)
+
+func TestGuestDeleteCodeAndImages(t *testing.T) {
+	db := useTestDB(t)
+	gc, err := Guest.GenerateCode("root", nil, GuestCodeOptions{}, models.QuotaLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploader := fmt.Sprintf("guest:%d", gc.ID)
+	for i := 0; i < 2; i++ {
+		if err := createQuotaImage(uploader, 100)(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := createQuotaImage("alice", 100)(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Guest.DeleteCodeAndImages(fmt.Sprint(gc.ID)); err != nil {
+		t.Fatalf("DeleteCodeAndImages: %v", err)
+	}
+
+	// 游客码不可再登录或使用，但记录保留
+	if _, err := Guest.Login(gc.Code); !errors.Is(err, ErrGuestCodeInvalid) {
+		t.Fatalf("Login after delete: err = %v", err)
+	}
+	if _, err := Guest.CodeFor(uploader); !errors.Is(err, ErrGuestCodeInvalid) {
+		t.Fatalf("CodeFor after delete: err = %v", err)
+	}
+	var kept models.GuestCode
+	if err := db.Unscoped().First(&kept, gc.ID).Error; err != nil || !kept.DeletedAt.Valid {
+		t.Fatalf("guest code not soft-deleted: %+v, err = %v", kept, err)
+	}
+
+	// 图片移入回收站，其他上传者的图片不受影响
+	var live, trashed int64
+	db.Model(&models.Image{}).Where("uploader = ?", uploader).Count(&live)
+	db.Unscoped().Model(&models.Image{}).Where("uploader = ? AND deleted_at IS NOT NULL", uploader).Count(&trashed)
+	if live != 0 || trashed != 2 {
+		t.Fatalf("guest images: %d live, %d trashed", live, trashed)
+	}
+	db.Model(&models.Image{}).Where("uploader = ?", "alice").Count(&live)
+	if live != 1 {
+		t.Fatalf("other uploader images: %d live", live)
+	}
+
+	// 已删除的游客码不能再次删除
+	if err := Guest.DeleteCodeAndImages(fmt.Sprint(gc.ID)); err == nil {
+		t.Fatal("deleting a deleted code succeeded")
+	}
+}
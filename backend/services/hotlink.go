@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"image-host/config"
+	"image-host/database"
+	"image-host/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// hotlinkHitKey 拦截计数的聚合维度
+type hotlinkHitKey struct {
+	date        string
+	imageID     uint
+	refererHost string
+}
+
+// hotlinkUserTTL 用户级防盗链覆盖的缓存时间，修改后最迟在此时间后对其他实例生效
+const hotlinkUserTTL = 30 * time.Second
+
+// hotlinkUserEntry 缓存的用户级防盗链覆盖；账号不存在时 policy 与 domains 为空
+type hotlinkUserEntry struct {
+	policy    string
+	domains   string
+	expiresAt time.Time
+}
+
+// HotlinkService 防盗链校验；被拦截的请求先在内存中累加，由 StartFlushJob 定期写入 hotlink_hits。
+// 用户级覆盖缓存在内存中，图片输出路径无需每次查询 users 表
+type HotlinkService struct {
+	mu      sync.Mutex
+	pending map[hotlinkHitKey]int64
+
+	usersMu sync.RWMutex
+	users   map[string]hotlinkUserEntry
+}
+
+var Hotlink = &HotlinkService{pending: map[hotlinkHitKey]int64{}, users: map[string]hotlinkUserEntry{}}
+
+// HotlinkRefererStat 来源域名的拦截次数
+type HotlinkRefererStat struct {
+	RefererHost string `json:"referer_host"`
+	Hits        int64  `json:"hits"`
+}
+
+// HotlinkImageStat 图片的拦截次数
+type HotlinkImageStat struct {
+	ImageID  uint   `json:"image_id"`
+	UUID     string `json:"uuid"`
+	Uploader string `json:"uploader"`
+	Hits     int64  `json:"hits"`
+}
+
+// NormalizeDomains 规范化域名列表（小写、去除 *. 前缀与空项），返回逗号分隔形式
+func NormalizeDomains(list []string) string {
+	var out []string
+	for _, raw := range list {
+		for _, d := range strings.Split(raw, ",") {
+			d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "*.")
+			if d != "" {
+				out = append(out, d)
+			}
+		}
+	}
+	return strings.Join(out, ",")
+}
+
+// Check 判断请求来源是否允许访问图片。enforced 表示该图片启用了防盗链（响应需带 Vary: Referer）；
+// refererHost 为拦截时用于计数的来源域名
+func (s *HotlinkService) Check(img *models.Image, referer, requestHost string) (allowed, enforced bool, refererHost string) {
+	policy := img.HotlinkPolicy
+	domains := []string{img.HotlinkDomains}
+
+	// 用户级覆盖（游客上传的图片没有对应账号）
+	if !strings.HasPrefix(img.Uploader, "guest:") {
+		user := s.userSettings(img.Uploader)
+		if policy == models.HotlinkInherit {
+			policy = user.policy
+		}
+		domains = append(domains, user.domains)
+	}
+
+	switch policy {
+	case models.HotlinkOff:
+		return true, false, ""
+	case models.HotlinkInherit:
+		if !config.AppConfig.HotlinkProtection {
+			return true, false, ""
+		}
+	}
+
+	if referer == "" {
+		return config.AppConfig.HotlinkAllowEmptyReferer, true, "(empty)"
+	}
+	u, err := url.Parse(referer)
+	if err != nil || u.Hostname() == "" {
+		return false, true, "(invalid)"
+	}
+	host := strings.ToLower(u.Hostname())
+
+	// 本站域名始终允许
+	own := []string{hostOnly(requestHost)}
+	if pu, err := url.Parse(config.AppConfig.PublicBaseURL); err == nil && pu.Hostname() != "" {
+		own = append(own, pu.Hostname())
+	}
+	for _, d := range own {
+		if strings.EqualFold(host, d) {
+			return true, true, ""
+		}
+	}
+
+	allowedDomains := append([]string{}, config.AppConfig.HotlinkAllowedDomains...)
+	allowedDomains = append(allowedDomains, domains...)
+	for _, d := range strings.Split(NormalizeDomains(allowedDomains), ",") {
+		if d != "" && (host == d || strings.HasSuffix(host, "."+d)) {
+			return true, true, ""
+		}
+	}
+	return false, true, host
+}
+
+// userSettings 返回用户级防盗链覆盖，优先使用未过期的缓存
+func (s *HotlinkService) userSettings(username string) hotlinkUserEntry {
+	now := time.Now()
+	s.usersMu.RLock()
+	entry, ok := s.users[username]
+	s.usersMu.RUnlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry
+	}
+
+	var user models.User
+	err := database.DB.Select("hotlink_policy", "hotlink_domains").
+		Where("username = ?", username).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		// 查询失败时不缓存，沿用过期值（若有）
+		return entry
+	}
+	entry = hotlinkUserEntry{policy: user.HotlinkPolicy, domains: user.HotlinkDomains, expiresAt: now.Add(hotlinkUserTTL)}
+	s.usersMu.Lock()
+	s.users[username] = entry
+	s.usersMu.Unlock()
+	return entry
+}
+
+// InvalidateUser 修改或删除账号后清除其防盗链覆盖缓存
+func (s *HotlinkService) InvalidateUser(username string) {
+	s.usersMu.Lock()
+	delete(s.users, username)
+	s.usersMu.Unlock()
+}
+
+func hostOnly(hostport string) string {
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		return strings.ToLower(h)
+	}
+	return strings.ToLower(hostport)
+}
+
+// Record 记录一次被拦截的请求
+func (s *HotlinkService) Record(imageID uint, refererHost string) {
+	if len(refererHost) > 255 {
+		refererHost = refererHost[:255]
+	}
+	key := hotlinkHitKey{date: time.Now().Format("2006-01-02"), imageID: imageID, refererHost: refererHost}
+	s.mu.Lock()
+	s.pending[key]++
+	s.mu.Unlock()
+}
+
+// Flush 将内存中的计数写入数据库；写入失败的计数放回内存待下次写入，其余计数照常写入，返回最后一个错误
+func (s *HotlinkService) Flush() error {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = map[hotlinkHitKey]int64{}
+	s.mu.Unlock()
+
+	var lastErr error
+	for k, n := range pending {
+		date, _ := time.ParseInLocation("2006-01-02", k.date, time.Local)
+		err := database.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "date"}, {Name: "image_id"}, {Name: "referer_host"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"hits": gorm.Expr("hits + ?", n), "updated_at": time.Now()}),
+		}).Create(&models.HotlinkHit{Date: date, ImageID: k.imageID, RefererHost: k.refererHost, Hits: n}).Error
+		if err != nil {
+			s.mu.Lock()
+			s.pending[k] += n
+			s.mu.Unlock()
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// StartFlushJob 每分钟写入一次拦截计数，退出时写入剩余计数
+func (s *HotlinkService) StartFlushJob() {
+	flush := func() error {
+		if err := s.Flush(); err != nil {
+			log.Printf("Failed to flush hotlink hits: %v", err)
+			return err
+		}
+		return nil
+	}
+	Jobs.Every("hotlink-flush", time.Minute, func(context.Context) { _ = flush() })
+	Jobs.OnStop("hotlink-flush", flush)
+}
+
+// Stats 统计最近 days 天的拦截情况：来源域名与图片排行
+func (s *HotlinkService) Stats(days, limit int) ([]HotlinkRefererStat, []HotlinkImageStat, error) {
+	since := time.Now().AddDate(0, 0, -days+1).Format("2006-01-02")
+
+	var referers []HotlinkRefererStat
+	if err := database.DB.Model(&models.HotlinkHit{}).
+		Select("referer_host, SUM(hits) AS hits").
+		Where("date >= ?", since).
+		Group("referer_host").Order("hits DESC").Limit(limit).
+		Scan(&referers).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var images []HotlinkImageStat
+	if err := database.DB.Model(&models.HotlinkHit{}).
+		Select("hotlink_hits.image_id, images.uuid, images.uploader, SUM(hotlink_hits.hits) AS hits").
+		Joins("LEFT JOIN images ON images.id = hotlink_hits.image_id").
+		Where("hotlink_hits.date >= ?", since).
+		Group("hotlink_hits.image_id, images.uuid, images.uploader").Order("hits DESC").Limit(limit).
+		Scan(&images).Error; err != nil {
+		return nil, nil, err
+	}
+	return referers, images, nil
+}
@@ -10,6 +10,9 @@ import (
 	"mime/multipart"
 	"strings"
 
+	"image-host/config"
+
+	"github.com/HugoSmits86/nativewebp"
 	"github.com/disintegration/imaging"
 )
 
@@ -33,12 +36,33 @@ func (s *ImageService) ProcessImage(file multipart.File, header *multipart.FileH
 	// 重置文件指针
 	file.Seek(0, 0)
 
+	return s.ProcessImageBytes(fileBytes)
+}
+
+// ProcessImageBytes 处理已读入内存的图片（归档导入、远程抓取等非表单来源）
+func (s *ImageService) ProcessImageBytes(fileBytes []byte) (*ProcessedImage, error) {
+	originalSize := len(fileBytes)
+
 	// 解码图片
 	img, format, err := image.Decode(bytes.NewReader(fileBytes))
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode image: %v", err)
 	}
 
+	// 按 EXIF 方向旋正（尺寸与缩略图总以显示方向为准），再按部署策略处理元数据
+	exif := ExtractExif(fileBytes)
+	orientation := 0
+	if exif != nil {
+		orientation = exif.Orientation
+	}
+	if orientation > 1 {
+		img = applyOrientation(img, orientation)
+	}
+	fileBytes, err = s.applyMetadataPolicy(fileBytes, img, format, orientation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply metadata policy: %v", err)
+	}
+
 	// 获取图片尺寸
 	bounds := img.Bounds()
 	width := bounds.Dx()
@@ -46,7 +70,7 @@ func (s *ImageService) ProcessImage(file multipart.File, header *multipart.FileH
 
 	// 压缩图片（如果需要）
 	compressedBytes := fileBytes
-	if header.Size > 1024*1024 { // 大于1MB时压缩
+	if originalSize > 1024*1024 { // 大于1MB时压缩
 		compressedBytes, err = s.compressImage(img, format, 85) // 85%质量
 		if err != nil {
 			return nil, fmt.Errorf("failed to compress image: %v", err)
@@ -60,16 +84,89 @@ func (s *ImageService) ProcessImage(file multipart.File, header *multipart.FileH
 	}
 
 	return &ProcessedImage{
-		OriginalBytes:  fileBytes,
+		OriginalBytes:   fileBytes,
 		CompressedBytes: compressedBytes,
-		ThumbnailBytes: thumbnailBytes,
-		Width:          width,
-		Height:         height,
-		Format:         format,
-		MimeType:       header.Header.Get("Content-Type"),
+		ThumbnailBytes:  thumbnailBytes,
+		Width:           width,
+		Height:          height,
+		Format:          format,
+		MimeType:        "image/" + format,
+		Ext:             formatExtensions["image/"+format],
+		Exif:            exif,
 	}, nil
 }
 
+// applyMetadataPolicy 按 METADATA_POLICY 返回最终保存的文件内容，格式始终与原图一致。
+// 带旋转方向时 orient 与 strip 都先旋正像素再按原格式重新编码，保证保存的文件与记录的宽高一致：
+// orient 保留其余元数据（Orientation 置为 1），strip 丢弃全部元数据。
+// 不需要旋转时 orient 原样保存，strip 无损移除元数据；文件结构异常无法可靠移除时改为重新编码像素
+func (s *ImageService) applyMetadataPolicy(data []byte, oriented image.Image, format string, orientation int) ([]byte, error) {
+	policy := config.AppConfig.MetadataPolicy
+	if policy == "keep" {
+		return data, nil
+	}
+	if orientation > 1 {
+		out, err := s.encodeOriginal(oriented, format)
+		if err != nil {
+			return nil, err
+		}
+		if policy == "orient" {
+			bounds := oriented.Bounds()
+			return copyMetadata(out, data, bounds.Dx(), bounds.Dy())
+		}
+		return out, nil
+	}
+	if policy == "orient" {
+		return data, nil
+	}
+	out, err := StripMetadata(data)
+	if err != nil {
+		return s.encodeOriginal(oriented, format)
+	}
+	return out, nil
+}
+
+// encodeOriginal 以原格式重新编码原图（JPEG 92% 质量，PNG 与 WebP 无损），不支持的格式返回错误
+func (s *ImageService) encodeOriginal(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 92})
+	case "png":
+		err = png.Encode(&buf, img)
+	case "webp":
+		err = nativewebp.Encode(&buf, img, nil)
+	default:
+		return nil, fmt.Errorf("cannot re-encode %s image", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// applyOrientation 按 EXIF Orientation（1-8）变换像素
+func applyOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	}
+	return img
+}
+
 // compressImage 压缩图片
 func (s *ImageService) compressImage(img image.Image, format string, quality int) ([]byte, error) {
 	var buf bytes.Buffer
@@ -127,26 +224,94 @@ func (s *ImageService) generateThumbnail(img image.Image, format string, maxWidt
 	return buf.Bytes(), nil
 }
 
-// ValidateImage 验证图片格式和大小
+// formatExtensions 支持的图片格式及其存储扩展名
+var formatExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// DetectImageType 根据文件头（magic bytes）识别图片类型，无法识别时返回空字符串
+func DetectImageType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return "image/jpeg"
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return "image/gif"
+	case len(data) >= 12 && bytes.Equal(data[0:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return "image/webp"
+	}
+	return ""
+}
+
+// ValidateImage 验证图片格式和大小：以文件内容识别的类型为准，不信任客户端 Content-Type
 func (s *ImageService) ValidateImage(header *multipart.FileHeader, allowedTypes []string, maxSize int64) error {
 	// 检查文件大小
 	if header.Size > maxSize {
 		return fmt.Errorf("file size exceeds limit: %d bytes", maxSize)
 	}
 
+	file, err := header.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read file: %v", err)
+	}
+
+	_, err = s.ValidateImageBytes(data, header.Header.Get("Content-Type"), allowedTypes, maxSize)
+	return err
+}
+
+// ValidateImageBytes 校验图片内容并返回识别出的 MIME 类型。
+// claimedType 为客户端声明的类型，声明为图片类型但与实际内容不符时拒绝。
+func (s *ImageService) ValidateImageBytes(data []byte, claimedType string, allowedTypes []string, maxSize int64) (string, error) {
+	if int64(len(data)) > maxSize {
+		return "", fmt.Errorf("file size exceeds limit: %d bytes", maxSize)
+	}
+
 	// 检查文件类型
-	mimeType := header.Header.Get("Content-Type")
+	mimeType := DetectImageType(data)
 	if mimeType == "" {
-		return fmt.Errorf("missing content type")
+		return "", fmt.Errorf("unrecognized image format")
+	}
+	claimed := strings.ToLower(strings.TrimSpace(strings.Split(claimedType, ";")[0]))
+	if claimed == "image/jpg" || claimed == "image/pjpeg" {
+		claimed = "image/jpeg"
+	}
+	if strings.HasPrefix(claimed, "image/") && claimed != mimeType {
+		return "", fmt.Errorf("content type mismatch: declared %s, detected %s", claimed, mimeType)
 	}
 
+	allowed := false
 	for _, allowedType := range allowedTypes {
 		if mimeType == allowedType {
-			return nil
+			allowed = true
+			break
 		}
 	}
+	if !allowed {
+		return "", fmt.Errorf("unsupported file type: %s", mimeType)
+	}
+
+	// 仅解析头部获取尺寸，在完整解码前拒绝像素数过大的图片
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("invalid image data: %v", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return "", fmt.Errorf("invalid image dimensions")
+	}
+	if maxPixels := config.AppConfig.MaxImagePixels; maxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return "", fmt.Errorf("image dimensions too large: %dx%d exceeds %d pixels", cfg.Width, cfg.Height, maxPixels)
+	}
 
-	return fmt.Errorf("unsupported file type: %s", mimeType)
+	return mimeType, nil
 }
 
 // ProcessedImage 处理后的图片数据
@@ -157,5 +322,15 @@ type ProcessedImage struct {
 	Width           int
 	Height          int
 	Format          string
-	MimeType        string
-}
\ No newline at end of file
+	MimeType        string // 由实际内容识别
+	Ext             string // 存储扩展名，由实际格式决定
+	Exif            *ExifInfo
+}
+
+// ThumbnailMimeType 缩略图编码格式：PNG 原图保持 PNG，其余为 JPEG（与 generateThumbnail 一致）
+func (p *ProcessedImage) ThumbnailMimeType() string {
+	if strings.ToLower(p.Format) == "png" {
+		return "image/png"
+	}
+	return "image/jpeg"
+}
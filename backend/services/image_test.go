@@ -0,0 +1,153 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"image-host/config"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// testImages 每种支持格式的 4x2 图片
+func testImages(t *testing.T) map[string][]byte {
+	images := map[string][]byte{}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testPixels(), nil); err != nil {
+		t.Fatal(err)
+	}
+	images["image/jpeg"] = append([]byte(nil), buf.Bytes()...)
+	buf.Reset()
+	if err := png.Encode(&buf, testPixels()); err != nil {
+		t.Fatal(err)
+	}
+	images["image/png"] = append([]byte(nil), buf.Bytes()...)
+	buf.Reset()
+	if err := gif.Encode(&buf, testPixels(), nil); err != nil {
+		t.Fatal(err)
+	}
+	images["image/gif"] = append([]byte(nil), buf.Bytes()...)
+	buf.Reset()
+	if err := nativewebp.Encode(&buf, testPixels(), nil); err != nil {
+		t.Fatal(err)
+	}
+	images["image/webp"] = append([]byte(nil), buf.Bytes()...)
+	return images
+}
+
+// pngBomb 仅含 IHDR 与空 IDAT 的 PNG，声明尺寸为 width x height
+func pngBomb(width, height uint32) []byte {
+	ihdr := binary.BigEndian.AppendUint32(nil, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0) // 8 位 RGBA
+	out := []byte("\x89PNG\r\n\x1a\n")
+	out = appendPNGChunk(out, "IHDR", ihdr)
+	out = appendPNGChunk(out, "IDAT", nil)
+	return appendPNGChunk(out, "IEND", nil)
+}
+
+// gifBomb 逻辑屏幕尺寸为 width x height 的 GIF 头
+func gifBomb(width, height uint16) []byte {
+	out := []byte("GIF89a")
+	out = binary.LittleEndian.AppendUint16(out, width)
+	out = binary.LittleEndian.AppendUint16(out, height)
+	out = append(out, 0, 0, 0)
+	// 图像描述符
+	out = append(out, 0x2C, 0, 0, 0, 0)
+	out = binary.LittleEndian.AppendUint16(out, width)
+	out = binary.LittleEndian.AppendUint16(out, height)
+	return append(out, 0, 2, 0, 0x3B)
+}
+
+func TestDetectImageType(t *testing.T) {
+	for want, data := range testImages(t) {
+		if got := DetectImageType(data); got != want {
+			t.Errorf("DetectImageType(%s) = %q", want, got)
+		}
+	}
+	for _, data := range [][]byte{
+		nil,
+		{0xFF, 0xD8},           // 截断的 JPEG 头
+		[]byte("\x89PNG\r\n"),  // 截断的 PNG 签名
+		[]byte("GIF87"),        // 截断的 GIF 头
+		[]byte("RIFF\x00\x00"), // 截断的 RIFF 头
+		[]byte("RIFF\x00\x00\x00\x00WAVE"),
+		[]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"/>"),
+		[]byte("BM\x00\x00"),
+	} {
+		if got := DetectImageType(data); got != "" {
+			t.Errorf("DetectImageType(%q) = %q, want empty", data, got)
+		}
+	}
+}
+
+func TestValidateImageBytes(t *testing.T) {
+	old := config.AppConfig
+	config.AppConfig = &config.Config{MaxImagePixels: 40000000}
+	t.Cleanup(func() { config.AppConfig = old })
+
+	s := &ImageService{}
+	images := testImages(t)
+	allowed := []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+	const maxSize = 1 << 20
+
+	// 每种允许的格式，声明类型一致、为空或为非图片类型时均通过
+	for mime, data := range images {
+		for _, claimed := range []string{mime, "", "application/octet-stream", mime + "; charset=binary"} {
+			got, err := s.ValidateImageBytes(data, claimed, allowed, maxSize)
+			if err != nil || got != mime {
+				t.Errorf("%s claimed %q: got %q, err = %v", mime, claimed, got, err)
+			}
+		}
+	}
+	if got, err := s.ValidateImageBytes(images["image/jpeg"], "image/jpg", allowed, maxSize); err != nil || got != "image/jpeg" {
+		t.Errorf("image/jpg alias: got %q, err = %v", got, err)
+	}
+
+	truncatedPNG := images["image/png"][:20]
+	cases := []struct {
+		name    string
+		data    []byte
+		claimed string
+		allowed []string
+		max     int64
+		errText string
+	}{
+		// 伪造的 Content-Type：以内容识别的类型为准
+		{"png claimed as jpeg", images["image/png"], "image/jpeg", allowed, maxSize, "content type mismatch"},
+		{"webp claimed as png", images["image/webp"], "image/png", allowed, maxSize, "content type mismatch"},
+		{"html claimed as png", []byte("<html><script>alert(1)</script></html>"), "image/png", allowed, maxSize, "unrecognized image format"},
+		// 截断的文件头
+		{"truncated jpeg signature", []byte{0xFF, 0xD8}, "image/jpeg", allowed, maxSize, "unrecognized image format"},
+		{"truncated png header", truncatedPNG, "image/png", allowed, maxSize, "invalid image data"},
+		{"truncated jpeg header", images["image/jpeg"][:10], "image/jpeg", allowed, maxSize, "invalid image data"},
+		// 解压炸弹：文件很小但声明尺寸巨大，仅读取头部即拒绝
+		{"png bomb", pngBomb(100000, 100000), "image/png", allowed, maxSize, "image dimensions too large"},
+		{"gif bomb", gifBomb(65535, 65535), "image/gif", allowed, maxSize, "image dimensions too large"},
+		{"png zero width", pngBomb(0, 10), "image/png", allowed, maxSize, "invalid image"},
+		// 未允许的格式与超出大小
+		{"gif not allowed", images["image/gif"], "image/gif", []string{"image/jpeg", "image/png"}, maxSize, "unsupported file type"},
+		{"too large", images["image/png"], "image/png", allowed, int64(len(images["image/png"]) - 1), "file size exceeds limit"},
+	}
+	for _, tc := range cases {
+		_, err := s.ValidateImageBytes(tc.data, tc.claimed, tc.allowed, tc.max)
+		if err == nil || !strings.Contains(err.Error(), tc.errText) {
+			t.Errorf("%s: err = %v, want %q", tc.name, err, tc.errText)
+		}
+	}
+
+	// 像素上限恰好等于图片像素数时允许
+	config.AppConfig.MaxImagePixels = 8
+	if _, err := s.ValidateImageBytes(images["image/png"], "", allowed, maxSize); err != nil {
+		t.Errorf("at pixel limit: %v", err)
+	}
+	config.AppConfig.MaxImagePixels = 7
+	if _, err := s.ValidateImageBytes(images["image/png"], "", allowed, maxSize); err == nil {
+		t.Error("over pixel limit accepted")
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// JobManager 管理后台任务的生命周期：定时任务、一次性异步任务与退出前的收尾操作。
+// Shutdown 时先取消 context 停止定时器，等待进行中的任务完成，再依次执行收尾操作
+type JobManager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	stopped bool
+	onStop  []stopHook
+	locks   map[string]*sync.Mutex // 按任务名，定时任务执行期间持有，同名收尾操作执行前获取
+}
+
+type stopHook struct {
+	name string
+	fn   func() error
+}
+
+var Jobs = NewJobManager()
+
+// NewJobManager 创建任务管理器
+func NewJobManager() *JobManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &JobManager{ctx: ctx, cancel: cancel, locks: map[string]*sync.Mutex{}}
+}
+
+// jobLock 取得任务名对应的锁
+func (m *JobManager) jobLock(name string) *sync.Mutex {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.locks[name]
+	if !ok {
+		l = &sync.Mutex{}
+		m.locks[name] = l
+	}
+	return l
+}
+
+// Context 任务共享的 context，Shutdown 时取消
+func (m *JobManager) Context() context.Context {
+	return m.ctx
+}
+
+// Every 每隔 interval 执行一次 fn，直到 Shutdown；fn 执行期间 Shutdown 会等待其返回，
+// 超时后同名的收尾操作仍会等待其返回再执行
+func (m *JobManager) Every(name string, interval time.Duration, fn func(ctx context.Context)) {
+	lock := m.jobLock(name)
+	m.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.ctx.Done():
+				log.Printf("Job %s stopped", name)
+				return
+			case <-ticker.C:
+				lock.Lock()
+				// 与 ctx.Done 同时就绪时 select 可能选中 ticker，取消后不再开始新的一轮
+				if m.ctx.Err() == nil {
+					fn(m.ctx)
+				}
+				lock.Unlock()
+			}
+		}
+	})
+}
+
+// Go 异步执行 fn 并纳入等待；Shutdown 开始后改为同步执行，避免任务被丢弃
+func (m *JobManager) Go(fn func()) {
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		fn()
+		return
+	}
+	m.wg.Add(1)
+	m.mu.Unlock()
+	go func() {
+		defer m.wg.Done()
+		fn()
+	}()
+}
+
+// OnStop 注册退出前的收尾操作（如写入内存中的计数），在所有任务结束后按注册顺序执行；
+// 与定时任务同名时，执行前获取该任务的锁，不会与仍在进行的一轮并发执行
+func (m *JobManager) OnStop(name string, fn func() error) {
+	m.mu.Lock()
+	m.onStop = append(m.onStop, stopHook{name: name, fn: fn})
+	m.mu.Unlock()
+}
+
+// Shutdown 停止定时任务并等待进行中的任务完成，超过 ctx 期限时不再等待并返回 ctx.Err()；
+// 无论是否超时都会执行收尾操作，尽量不丢失内存中的数据。超时后收尾操作仍会等待同名定时任务
+// 进行中的一轮结束，避免两者并发写入时失败的计数放回内存后被丢弃
+func (m *JobManager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		return nil
+	}
+	m.stopped = true
+	hooks := m.onStop
+	m.mu.Unlock()
+
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	for _, h := range hooks {
+		lock := m.jobLock(h.name)
+		lock.Lock()
+		herr := h.fn()
+		lock.Unlock()
+		if herr != nil {
+			log.Printf("Shutdown hook %s failed: %v", h.name, herr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJobManagerShutdownWaitsForRunningJob(t *testing.T) {
+	m := NewJobManager()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var running, runs atomic.Int32
+	m.Every("flush", time.Millisecond, func(ctx context.Context) {
+		if runs.Add(1) == 1 {
+			close(started)
+		}
+		running.Store(1)
+		<-release
+		running.Store(0)
+	})
+	var hookRanWhileRunning, hookRuns atomic.Int32
+	m.OnStop("flush", func() error {
+		hookRuns.Add(1)
+		hookRanWhileRunning.Store(running.Load())
+		return nil
+	})
+	<-started
+
+	// 等待超时后，收尾操作仍须等进行中的一轮结束才执行
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- m.Shutdown(ctx) }()
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown returned while the job was running: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if hookRuns.Load() != 0 {
+		t.Fatal("hook ran while the job was running")
+	}
+	close(release)
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown: err = %v, want deadline exceeded", err)
+	}
+	if hookRuns.Load() != 1 || hookRanWhileRunning.Load() != 0 {
+		t.Fatalf("hook runs = %d, ran while job running = %v", hookRuns.Load(), hookRanWhileRunning.Load() == 1)
+	}
+
+	// 取消后不再开始新的一轮
+	n := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	if runs.Load() != n {
+		t.Fatal("job ran again after Shutdown")
+	}
+}
+
+func TestJobManagerShutdownHooks(t *testing.T) {
+	m := NewJobManager()
+	var order []string
+	m.OnStop("a", func() error { order = append(order, "a"); return nil })
+	m.OnStop("b", func() error { order = append(order, "b"); return errors.New("fail") })
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if len(order) != 2 || order[0] != "a" || order[1] != "b" {
+		t.Fatalf("hooks ran in order %v", order)
+	}
+	// Shutdown 之后的任务同步执行，不会丢弃
+	ran := false
+	m.Go(func() { ran = true })
+	if !ran {
+		t.Fatal("Go after Shutdown did not run synchronously")
+	}
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatalf("second Shutdown: %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"image-host/config"
+	"image-host/database"
+	"image-host/models"
+
+	"gorm.io/gorm"
+)
+
+// LibraryService 图片记录的生命周期管理（回收站、删除等），供各控制器与后台任务共用
+type LibraryService struct{}
+
+var Library = &LibraryService{}
+
+// Trash 移入回收站：软删除记录，保留文件及相册、标签、分享链接等关系，可通过 Restore 恢复
+func (s *LibraryService) Trash(img *models.Image) error {
+	return database.DB.Delete(img).Error
+}
+
+// Restore 从回收站恢复
+func (s *LibraryService) Restore(img *models.Image) error {
+	return database.DB.Unscoped().Model(img).Update("deleted_at", nil).Error
+}
+
+// FindTrashed 查找回收站中的图片，uploader 非空时限定上传者
+func (s *LibraryService) FindTrashed(uuid, uploader string) (*models.Image, error) {
+	var img models.Image
+	q := database.DB.Unscoped().Where("uuid = ? AND deleted_at IS NOT NULL", uuid)
+	if uploader != "" {
+		q = q.Where("uploader = ?", uploader)
+	}
+	if err := q.First(&img).Error; err != nil {
+		return nil, err
+	}
+	return &img, nil
+}
+
+// purgeBatchSize 清理回收站时每批加载的记录数
+const purgeBatchSize = 200
+
+// PurgeTrash 彻底删除回收站中在 before 之前删除的图片；uploader 非空时限定上传者。
+// 按 ID 分批处理，单张删除失败时记录日志并继续，返回删除数量与最后一个错误
+func (s *LibraryService) PurgeTrash(uploader string, before time.Time) (int, error) {
+	var (
+		n       int
+		lastErr error
+		lastID  uint
+	)
+	for {
+		var images []models.Image
+		q := database.DB.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ? AND id > ?", before, lastID)
+		if uploader != "" {
+			q = q.Where("uploader = ?", uploader)
+		}
+		if err := q.Order("id ASC").Limit(purgeBatchSize).Find(&images).Error; err != nil {
+			return n, err
+		}
+		for i := range images {
+			if err := s.Delete(&images[i]); err != nil {
+				log.Printf("Failed to purge image %s: %v", images[i].UUID, err)
+				lastErr = err
+				continue
+			}
+			n++
+		}
+		if len(images) < purgeBatchSize {
+			return n, lastErr
+		}
+		lastID = images[len(images)-1].ID
+	}
+}
+
+// StartTrashPurgeJob 每小时彻底删除超过 TRASH_RETENTION_DAYS 的回收站图片（0 表示不自动清理）
+func (s *LibraryService) StartTrashPurgeJob() {
+	if config.AppConfig.TrashRetentionDays <= 0 {
+		return
+	}
+	Jobs.Every("trash-purge", time.Hour, func(context.Context) {
+		before := time.Now().AddDate(0, 0, -config.AppConfig.TrashRetentionDays)
+		n, err := s.PurgeTrash("", before)
+		if err != nil {
+			log.Printf("Failed to purge trash: %v", err)
+		}
+		if n > 0 {
+			log.Printf("Purged %d images from trash", n)
+		}
+	})
+}
+
+// Delete 彻底删除：释放存储对象引用（无其他记录引用时删除原图与缩略图），清理相册、标签、分享链接与访问统计，再硬删除记录。
+// 释放引用失败时不删除记录，以便之后重试，避免存储对象无人引用
+func (s *LibraryService) Delete(img *models.Image) error {
+	if err := Objects.Release(img); err != nil {
+		return err
+	}
+	_ = Albums.DetachImage(img.ID)
+	_ = Tags.DetachImage(img.ID)
+	_ = Shares.DetachImage(img.ID)
+	database.DB.Where("image_id = ?", img.ID).Delete(&models.HotlinkHit{})
+	_ = Traffic.DetachImage(img.ID)
+	return database.DB.Unscoped().Delete(img).Error
+}
+
+// Transfer 在事务 tx 中将图片转给 owner：移出不属于 owner 的相册（并清除以其为封面的设置），
+// 分享链接改归 owner 管理，再修改上传者
+func (s *LibraryService) Transfer(tx *gorm.DB, img *models.Image, owner string) error {
+	foreign := tx.Unscoped().Model(&models.Album{}).Select("id").Where("owner <> ?", owner)
+	if err := tx.Where("image_id = ? AND album_id IN (?)", img.ID, foreign).Delete(&models.AlbumImage{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Model(&models.Album{}).
+		Where("cover_image_id = ? AND owner <> ?", img.ID, owner).
+		Update("cover_image_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.ShareLink{}).Where("image_id = ?", img.ID).Update("owner", owner).Error; err != nil {
+		return err
+	}
+	return tx.Model(img).Update("uploader", owner).Error
+}
+
+// FindByFileKey 按存储 key 查找可公开访问（非私有）的图片，key 可为原图或缩略图；
+// 去重后同一文件可能被多条记录引用，任一非私有即可访问。thumb 表示 key 为缩略图
+func (s *LibraryService) FindByFileKey(key string) (*models.Image, bool, error) {
+	var img models.Image
+	err := database.DB.Where("r2_key = ? AND visibility <> ?", key, models.VisibilityPrivate).First(&img).Error
+	if err == nil {
+		return &img, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	// 缩略图 key 形如 <原图去扩展名>_thumb.jpg|.png
+	ext := path.Ext(key)
+	base := strings.TrimSuffix(key, ext)
+	if !strings.HasSuffix(base, "_thumb") {
+		return nil, false, gorm.ErrRecordNotFound
+	}
+	base = strings.TrimSuffix(base, "_thumb")
+	var candidates []models.Image
+	if err := database.DB.Where("r2_key LIKE ? AND thumbnail_url <> '' AND visibility <> ?", escapeLike(base)+".%", models.VisibilityPrivate).
+		Find(&candidates).Error; err != nil {
+		return nil, false, err
+	}
+	for i := range candidates {
+		if imageThumbnailKey(&candidates[i]) == key {
+			return &candidates[i], true, nil
+		}
+	}
+	return nil, false, gorm.ErrRecordNotFound
+}
+
+// ThumbnailKey 图片缩略图的存储 key，无缩略图时为空
+func (s *LibraryService) ThumbnailKey(img *models.Image) string {
+	return imageThumbnailKey(img)
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"errors"
+	"log"
+
+	"image-host/database"
+	"image-host/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ObjectService 维护按内容去重的存储对象及其引用计数
+type ObjectService struct{}
+
+var Objects = &ObjectService{}
+
+// ContentHash 计算内容的 SHA-256（hex）
+func ContentHash(data []byte) string {
+	return sha256Hex(data)
+}
+
+// Acquire 查找相同内容的已存储对象并增加引用；不存在时返回 nil
+func (s *ObjectService) Acquire(hash string) (*models.StoredObject, error) {
+	var obj models.StoredObject
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("content_hash = ?", hash).First(&obj).Error; err != nil {
+			return err
+		}
+		obj.RefCount++
+		return tx.Model(&obj).Update("ref_count", gorm.Expr("ref_count + 1")).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &obj, nil
+}
+
+// Register 登记新写入的对象（引用计数为 1）。
+// 若并发上传已登记了相同内容，则改为引用已有对象并返回 duplicate=true，调用方应删除自己写入的文件。
+func (s *ObjectService) Register(obj *models.StoredObject) (*models.StoredObject, bool, error) {
+	obj.RefCount = 1
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(obj)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return obj, false, nil
+	}
+	existing, err := s.Acquire(obj.ContentHash)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing == nil {
+		return nil, false, gorm.ErrRecordNotFound
+	}
+	return existing, true, nil
+}
+
+// Release 释放图片对存储对象的引用，引用归零时删除文件。
+// 未记录哈希的旧数据直接删除文件。返回错误时引用未释放，可重试；
+// 引用已释放但删除文件失败时仅记录日志（文件成为孤儿，不能再次释放引用）
+func (s *ObjectService) Release(img *models.Image) error {
+	if img.ContentHash == "" {
+		return R2.DeleteImageFiles(img)
+	}
+
+	removeFiles := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var obj models.StoredObject
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("content_hash = ?", img.ContentHash).First(&obj).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				removeFiles = true
+				return nil
+			}
+			return err
+		}
+		if obj.RefCount > 1 {
+			return tx.Model(&obj).Update("ref_count", gorm.Expr("ref_count - 1")).Error
+		}
+		removeFiles = true
+		return tx.Delete(&obj).Error
+	})
+	if err != nil {
+		return err
+	}
+	if removeFiles {
+		if err := R2.DeleteImageFiles(img); err != nil {
+			log.Printf("Failed to delete stored files %s (object released): %v", img.R2Key, err)
+		}
+	}
+	return nil
+}
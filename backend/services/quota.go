@@ -0,0 +1,201 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"image-host/config"
+	"image-host/database"
+	"image-host/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type QuotaService struct{}
+
+var Quotas = &QuotaService{}
+
+// Quota 生效的配额，0 表示不限
+type Quota struct {
+	MaxBytes  int64 `json:"max_bytes"`
+	MaxImages int64 `json:"max_images"`
+	MaxDaily  int64 `json:"max_daily"`
+}
+
+// QuotaUsage 当前用量；Bytes 含回收站中尚未彻底删除的图片（TrashBytes），其文件仍占用存储
+type QuotaUsage struct {
+	Bytes      int64 `json:"bytes"`
+	TrashBytes int64 `json:"trash_bytes"`
+	Images     int64 `json:"images"`
+	Today      int64 `json:"today"`
+}
+
+// QuotaExceededError 超出配额，Limit 为触发的限制项（bytes / images / daily）
+type QuotaExceededError struct {
+	Limit string
+	Max   int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	switch e.Limit {
+	case "bytes":
+		return fmt.Sprintf("Storage quota exceeded (max %d bytes)", e.Max)
+	case "images":
+		return fmt.Sprintf("Image count quota exceeded (max %d images)", e.Max)
+	default:
+		return fmt.Sprintf("Daily upload quota exceeded (max %d uploads per day)", e.Max)
+	}
+}
+
+// Limits 计算生效配额：用户或游客码的覆盖优先，其次全局默认；admin 不受限制
+func (s *QuotaService) Limits(username, role string) Quota {
+	if role == models.RoleAdmin {
+		return Quota{}
+	}
+	q := Quota{
+		MaxBytes:  config.AppConfig.QuotaMaxBytes,
+		MaxImages: config.AppConfig.QuotaMaxImages,
+		MaxDaily:  config.AppConfig.QuotaMaxDaily,
+	}
+
+	var limits models.QuotaLimits
+	if id, ok := strings.CutPrefix(username, "guest:"); ok {
+		var gc models.GuestCode
+		if n, err := strconv.ParseUint(id, 10, 64); err == nil && database.DB.First(&gc, n).Error == nil {
+			limits = gc.QuotaLimits
+		}
+	} else {
+		var user models.User
+		if database.DB.Where("username = ?", username).First(&user).Error == nil {
+			limits = user.QuotaLimits
+		}
+	}
+	if limits.QuotaMaxBytes != nil {
+		q.MaxBytes = *limits.QuotaMaxBytes
+	}
+	if limits.QuotaMaxImages != nil {
+		q.MaxImages = *limits.QuotaMaxImages
+	}
+	if limits.QuotaMaxDaily != nil {
+		q.MaxDaily = *limits.QuotaMaxDaily
+	}
+	return q
+}
+
+// Usage 统计上传者当前用量（按图片记录计算，去重共享的存储对象同样计入）；
+// 字节数包含回收站中的图片直至彻底删除，图片数不含；今日上传数取自每日计数，删除或移入回收站后不会减少
+func (s *QuotaService) Usage(username string) (QuotaUsage, error) {
+	return s.usage(database.DB, username)
+}
+
+func (s *QuotaService) usage(db *gorm.DB, username string) (QuotaUsage, error) {
+	var u QuotaUsage
+	var row struct {
+		Images     int64
+		Bytes      int64
+		TrashBytes int64
+	}
+	if err := db.Unscoped().Model(&models.Image{}).
+		Select("COALESCE(SUM(CASE WHEN deleted_at IS NULL THEN 1 ELSE 0 END), 0) AS images, "+
+			"COALESCE(SUM(file_size), 0) AS bytes, "+
+			"COALESCE(SUM(CASE WHEN deleted_at IS NULL THEN 0 ELSE file_size END), 0) AS trash_bytes").
+		Where("uploader = ?", username).
+		Scan(&row).Error; err != nil {
+		return u, err
+	}
+	u.Images, u.Bytes, u.TrashBytes = row.Images, row.Bytes, row.TrashBytes
+
+	var counter models.UploadCounter
+	err := db.Where("uploader = ? AND date = ?", username, quotaToday()).Take(&counter).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return u, err
+	}
+	u.Today = counter.Uploads
+	return u, nil
+}
+
+// Check 校验再上传一个 size 字节的文件是否超出配额，超出时返回 *QuotaExceededError。
+// 仅用于处理图片前尽早拒绝，最终以 Record 为准
+func (s *QuotaService) Check(username, role string, size int64) error {
+	q := s.Limits(username, role)
+	if q == (Quota{}) {
+		return nil
+	}
+	u, err := s.Usage(username)
+	if err != nil {
+		return err
+	}
+	return q.check(u, size)
+}
+
+// Record 在上传者当日计数行上加锁后校验配额，通过时在同一事务中执行 create 并累加当日上传数。
+// 同一上传者的并发上传在此串行化，不会超出配额；超出时返回 *QuotaExceededError 且不执行 create
+func (s *QuotaService) Record(username, role string, size int64, create func(tx *gorm.DB) error) error {
+	return s.reserve(username, role, size, true, create)
+}
+
+// Assign 将 size 字节的已有图片转给 username 前加锁校验其存储字节与图片数量配额（不计每日上传数），
+// 通过时在同一事务中执行 apply
+func (s *QuotaService) Assign(username, role string, size int64, apply func(tx *gorm.DB) error) error {
+	return s.reserve(username, role, size, false, apply)
+}
+
+func (s *QuotaService) reserve(username, role string, size int64, upload bool, apply func(tx *gorm.DB) error) error {
+	q := s.Limits(username, role)
+	if !upload {
+		q.MaxDaily = 0
+	}
+	today := quotaToday()
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.UploadCounter{Uploader: username, Date: today}).Error; err != nil {
+			return err
+		}
+		var counter models.UploadCounter
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("uploader = ? AND date = ?", username, today).Take(&counter).Error; err != nil {
+			return err
+		}
+		if q != (Quota{}) {
+			u, err := s.usage(tx, username)
+			if err != nil {
+				return err
+			}
+			if err := q.check(u, size); err != nil {
+				return err
+			}
+		}
+		if err := apply(tx); err != nil {
+			return err
+		}
+		if !upload {
+			return nil
+		}
+		return tx.Model(&models.UploadCounter{}).
+			Where("uploader = ? AND date = ?", username, today).
+			UpdateColumn("uploads", gorm.Expr("uploads + 1")).Error
+	})
+}
+
+func (q Quota) check(u QuotaUsage, size int64) error {
+	if q.MaxImages > 0 && u.Images+1 > q.MaxImages {
+		return &QuotaExceededError{Limit: "images", Max: q.MaxImages}
+	}
+	if q.MaxDaily > 0 && u.Today+1 > q.MaxDaily {
+		return &QuotaExceededError{Limit: "daily", Max: q.MaxDaily}
+	}
+	if q.MaxBytes > 0 && u.Bytes+size > q.MaxBytes {
+		return &QuotaExceededError{Limit: "bytes", Max: q.MaxBytes}
+	}
+	return nil
+}
+
+// quotaToday 本地时区的今日零点，对应 upload_counters.date
+func quotaToday() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"image-host/config"
+	"image-host/models"
+
+	"gorm.io/gorm"
+)
+
+func setQuotaConfig(t *testing.T, maxBytes, maxImages, maxDaily int64) {
+	old := config.AppConfig
+	config.AppConfig = &config.Config{QuotaMaxBytes: maxBytes, QuotaMaxImages: maxImages, QuotaMaxDaily: maxDaily}
+	t.Cleanup(func() { config.AppConfig = old })
+}
+
+var quotaImageSeq atomic.Int64
+
+// createQuotaImage 返回在事务中写入一张属于 uploader 的图片的 create 回调
+func createQuotaImage(uploader string, size int64) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		n := quotaImageSeq.Add(1)
+		return tx.Create(&models.Image{
+			UUID:         fmt.Sprintf("00000000-0000-0000-0000-%012d", n),
+			OriginalName: "a.png",
+			FileName:     "a.png",
+			FileSize:     size,
+			MimeType:     "image/png",
+			R2Key:        "k",
+			PublicURL:    "u",
+			Uploader:     uploader,
+		}).Error
+	}
+}
+
+func TestQuotaRecordConcurrentAtLimit(t *testing.T) {
+	cases := []struct {
+		name                        string
+		maxBytes, maxImages, maxDay int64
+		limit                       string
+	}{
+		{"images", 0, 1, 0, "images"},
+		{"bytes", 150, 0, 0, "bytes"},
+		{"daily", 0, 0, 1, "daily"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			useTestDB(t)
+			setQuotaConfig(t, tc.maxBytes, tc.maxImages, tc.maxDay)
+
+			const n = 8
+			var wg sync.WaitGroup
+			errs := make([]error, n)
+			start := make(chan struct{})
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					<-start
+					errs[i] = Quotas.Record("alice", models.RoleMember, 100, createQuotaImage("alice", 100))
+				}(i)
+			}
+			close(start)
+			wg.Wait()
+
+			ok := 0
+			for _, err := range errs {
+				var qe *QuotaExceededError
+				switch {
+				case err == nil:
+					ok++
+				case errors.As(err, &qe):
+					if qe.Limit != tc.limit {
+						t.Errorf("limit = %s, want %s", qe.Limit, tc.limit)
+					}
+				default:
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+			if ok != 1 {
+				t.Fatalf("%d of %d concurrent reserves succeeded, want exactly 1", ok, n)
+			}
+			u, err := Quotas.Usage("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.Images != 1 || u.Bytes != 100 || u.Today != 1 {
+				t.Fatalf("usage = %+v, want 1 image, 100 bytes, 1 today", u)
+			}
+		})
+	}
+}
+
+func TestQuotaRecordFailedCreateReleases(t *testing.T) {
+	useTestDB(t)
+	setQuotaConfig(t, 0, 1, 1)
+
+	// 写入失败时事务回滚：不计入图片数与当日上传数
+	ingestErr := errors.New("insert failed")
+	err := Quotas.Record("alice", models.RoleMember, 100, func(tx *gorm.DB) error {
+		if err := createQuotaImage("alice", 100)(tx); err != nil {
+			return err
+		}
+		return ingestErr
+	})
+	if !errors.Is(err, ingestErr) {
+		t.Fatalf("Record: err = %v, want ingest error", err)
+	}
+	u, err := Quotas.Usage("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u != (QuotaUsage{}) {
+		t.Fatalf("usage after failed ingest = %+v, want zero", u)
+	}
+
+	// 释放的额度可再次使用
+	if err := Quotas.Record("alice", models.RoleMember, 100, createQuotaImage("alice", 100)); err != nil {
+		t.Fatalf("Record after failed ingest: %v", err)
+	}
+	var qe *QuotaExceededError
+	if err := Quotas.Record("alice", models.RoleMember, 100, createQuotaImage("alice", 100)); !errors.As(err, &qe) {
+		t.Fatalf("Record over limit: err = %v", err)
+	}
+
+	// 超出配额时不执行 create
+	called := false
+	Quotas.Record("alice", models.RoleMember, 100, func(tx *gorm.DB) error {
+		called = true
+		return nil
+	})
+	if called {
+		t.Fatal("create ran although the quota was exceeded")
+	}
+}
+
+func TestQuotaAssign(t *testing.T) {
+	useTestDB(t)
+	setQuotaConfig(t, 250, 0, 1)
+
+	// 转入不计每日上传数，但受字节配额限制
+	for i := 0; i < 2; i++ {
+		if err := Quotas.Assign("bob", models.RoleMember, 100, createQuotaImage("bob", 100)); err != nil {
+			t.Fatalf("Assign %d: %v", i, err)
+		}
+	}
+	var qe *QuotaExceededError
+	if err := Quotas.Assign("bob", models.RoleMember, 100, createQuotaImage("bob", 100)); !errors.As(err, &qe) || qe.Limit != "bytes" {
+		t.Fatalf("Assign over byte quota: err = %v", err)
+	}
+	u, err := Quotas.Usage("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Images != 2 || u.Bytes != 200 || u.Today != 0 {
+		t.Fatalf("usage = %+v", u)
+	}
+
+	// 管理员不受限制
+	if err := Quotas.Assign("root", models.RoleAdmin, 1000, createQuotaImage("root", 1000)); err != nil {
+		t.Fatalf("Assign to admin: %v", err)
+	}
+}
+
+func TestQuotaLimitsOverrides(t *testing.T) {
+	db := useTestDB(t)
+	setQuotaConfig(t, 1000, 10, 5)
+
+	one := int64(1)
+	if err := db.Create(&models.User{Username: "carol", PasswordHash: "x", Role: models.RoleMember,
+		QuotaLimits: models.QuotaLimits{QuotaMaxImages: &one}}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := Quotas.Limits("carol", models.RoleMember); got != (Quota{MaxBytes: 1000, MaxImages: 1, MaxDaily: 5}) {
+		t.Fatalf("Limits(carol) = %+v", got)
+	}
+	if got := Quotas.Limits("dave", models.RoleMember); got != (Quota{MaxBytes: 1000, MaxImages: 10, MaxDaily: 5}) {
+		t.Fatalf("Limits(dave) = %+v", got)
+	}
+	if got := Quotas.Limits("carol", models.RoleAdmin); got != (Quota{}) {
+		t.Fatalf("Limits(admin) = %+v", got)
+	}
+}
@@ -1,85 +1,121 @@
 package services
 
 import (
+	"bytes"
 	"fmt"
 	"io"
-	"mime/multipart"
-	"os"
+	"log"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"image-host/config"
+	"image-host/models"
 
 	"github.com/google/uuid"
 )
 
-type R2Service struct{}
+// R2Service 文件存储服务（历史命名），实际读写委托给 StorageBackend
+type R2Service struct {
+	backend StorageBackend
+}
 
 var R2 *R2Service
 
-// InitR2Service 初始化本地存储服务
+// InitR2Service 按 STORAGE_DRIVER 初始化存储服务
 func InitR2Service() {
-	_ = os.MkdirAll(config.AppConfig.UploadPath, 0755)
-	R2 = &R2Service{}
+	backend, err := NewStorageBackend(config.AppConfig)
+	if err != nil {
+		log.Fatal("Failed to initialize storage backend:", err)
+	}
+	R2 = &R2Service{backend: backend}
 }
 
-// UploadFile 上传文件到 R2 (如果失败则使用本地存储)
-func (r *R2Service) UploadFile(file multipart.File, header *multipart.FileHeader) (string, string, error) {
-	// 生成唯一文件名
-	uuid := uuid.New().String()
-	ext := filepath.Ext(header.Filename)
-	fileName := fmt.Sprintf("%s%s", uuid, ext)
+// NewR2Service 使用指定存储后端创建服务
+func NewR2Service(backend StorageBackend) *R2Service {
+	return &R2Service{backend: backend}
+}
 
-	// 按日期组织文件路径
+// NewKey 生成按日期组织的唯一存储 key
+func (r *R2Service) NewKey(ext string) string {
 	now := time.Now()
-	key := fmt.Sprintf("images/%d/%02d/%02d/%s", now.Year(), now.Month(), now.Day(), fileName)
+	fileName := fmt.Sprintf("%s%s", uuid.New().String(), ext)
+	return fmt.Sprintf("images/%d/%02d/%02d/%s", now.Year(), now.Month(), now.Day(), fileName)
+}
 
-	// 读取文件内容
-	fileBytes, err := io.ReadAll(file)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to read file: %v", err)
+// PutFile 按指定 key 写入数据并返回访问 URL
+func (r *R2Service) PutFile(key string, data []byte, contentType string) (string, error) {
+	if err := r.backend.Put(key, data, contentType); err != nil {
+		return "", err
 	}
+	return r.backend.URL(key), nil
+}
 
-	// 重置文件指针
-	file.Seek(0, 0)
+// OpenFile 读取已存储的文件，调用方负责关闭
+func (r *R2Service) OpenFile(key string) (io.ReadCloser, error) {
+	return r.backend.Get(key)
+}
 
-	// 直接使用本地存储（移除对 R2 的依赖）
-	return r.uploadToLocal(fileBytes, key)
+// OpenSeeker 以可 Seek 的方式读取文件，调用方负责关闭：后端支持按区间读取时按需下载，
+// 本地文件直接使用，其他后端读入内存
+func (r *R2Service) OpenSeeker(key string) (io.ReadSeekCloser, error) {
+	if sb, ok := r.backend.(SeekableBackend); ok {
+		return sb.Open(key)
+	}
+	rc, err := r.backend.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if rs, ok := rc.(io.ReadSeekCloser); ok {
+		return rs, nil
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	return nopSeekCloser{bytes.NewReader(data)}, nil
 }
 
-// DeleteFile 删除本地文件
+type nopSeekCloser struct{ io.ReadSeeker }
+
+func (nopSeekCloser) Close() error { return nil }
+
+// DeleteFile 删除文件（不存在时不报错）
 func (r *R2Service) DeleteFile(key string) error {
-	localPath := filepath.Join(config.AppConfig.UploadPath, key)
-	if _, err := os.Stat(localPath); err == nil {
-		if err := os.Remove(localPath); err != nil {
-			return fmt.Errorf("failed to delete local file: %v", err)
-		}
-	}
-	return nil
+	return r.backend.Delete(key)
 }
 
-// GetFileURL 获取文件的公共 URL（相对路径）
+// GetFileURL 获取文件的访问 URL
 func (r *R2Service) GetFileURL(key string) string {
-	return fmt.Sprintf("/uploads/%s", key)
+	return r.backend.URL(key)
 }
 
-// uploadToLocal 本地存储备用方案
-func (r *R2Service) uploadToLocal(fileBytes []byte, key string) (string, string, error) {
-	// 创建本地存储目录
-	localPath := filepath.Join(config.AppConfig.UploadPath, key)
-	localDir := filepath.Dir(localPath)
-
-	if err := os.MkdirAll(localDir, 0755); err != nil {
-		return "", "", fmt.Errorf("failed to create local directory: %v", err)
+// ThumbnailKey 由原图 key 推导缩略图 key：images/.../<uuid>_thumb.jpg|png
+func ThumbnailKey(key, thumbnailMimeType string) string {
+	ext := ".jpg"
+	if thumbnailMimeType == "image/png" {
+		ext = ".png"
 	}
+	return strings.TrimSuffix(key, filepath.Ext(key)) + "_thumb" + ext
+}
 
-	// 写入文件到本地
-	if err := os.WriteFile(localPath, fileBytes, 0644); err != nil {
-		return "", "", fmt.Errorf("failed to write local file: %v", err)
+// imageThumbnailKey 根据已保存的 ThumbnailURL 还原缩略图 key
+func imageThumbnailKey(img *models.Image) string {
+	if img.R2Key == "" || img.ThumbnailURL == "" {
+		return ""
 	}
+	ext := filepath.Ext(img.ThumbnailURL)
+	return strings.TrimSuffix(img.R2Key, filepath.Ext(img.R2Key)) + "_thumb" + ext
+}
 
-	// 生成相对访问 URL，便于在任意域名/端口下工作
-	localURL := fmt.Sprintf("/uploads/%s", key)
-
-	return key, localURL, nil
+// DeleteImageFiles 删除图片原图及缩略图（忽略不存在错误）
+func (r *R2Service) DeleteImageFiles(img *models.Image) error {
+	if thumbKey := imageThumbnailKey(img); thumbKey != "" {
+		_ = r.DeleteFile(thumbKey)
+	}
+	if img.R2Key == "" {
+		return nil
+	}
+	return r.DeleteFile(img.R2Key)
 }
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"image-host/config"
+)
+
+var (
+	ErrRemoteURLInvalid     = errors.New("invalid url, only http and https are supported")
+	ErrRemoteAddressBlocked = errors.New("remote address is not allowed")
+	ErrRemoteTooManyHops    = errors.New("too many redirects")
+	ErrRemoteTooLarge       = errors.New("remote file exceeds size limit")
+)
+
+// RemoteStatusError 远程服务器返回非 2xx 状态
+type RemoteStatusError struct {
+	StatusCode int
+}
+
+func (e *RemoteStatusError) Error() string {
+	return fmt.Sprintf("remote server returned status %d", e.StatusCode)
+}
+
+// RemoteFile 下载得到的远程文件
+type RemoteFile struct {
+	Data        []byte
+	ContentType string
+	FileName    string
+	FinalURL    string
+}
+
+// RemoteFetchService 下载远程图片。连接建立时校验实际连接的 IP，拒绝回环、内网、链路本地等地址，
+// 重定向后的地址与 DNS 重绑定同样受限
+type RemoteFetchService struct {
+	// 以下仅供测试替换：allowAddr 为空时只允许公网地址，resolver 为空时使用系统解析
+	allowAddr func(netip.Addr) bool
+	resolver  *net.Resolver
+
+	once   sync.Once
+	client *http.Client
+}
+
+var RemoteFetch = &RemoteFetchService{}
+
+// blockedPrefixes IsPrivate / IsLoopback 等未覆盖的保留地址段
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // 运营商级 NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // 基准测试
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 可映射到内网 IPv4
+}
+
+// publicAddress 是否为可访问的公网地址
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *RemoteFetchService) httpClient() *http.Client {
+	s.once.Do(func() {
+		allow := s.allowAddr
+		if allow == nil {
+			allow = publicAddress
+		}
+		dialer := &net.Dialer{
+			Timeout:  10 * time.Second,
+			Resolver: s.resolver,
+			// 在解析完成、实际连接前校验 IP，避免 DNS 返回内网地址或重绑定
+			Control: func(network, address string, _ syscall.RawConn) error {
+				ap, err := netip.ParseAddrPort(address)
+				if err != nil || !allow(ap.Addr().Unmap()) {
+					return ErrRemoteAddressBlocked
+				}
+				return nil
+			},
+		}
+		s.client = &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 nil, // 不经代理，确保校验的是实际连接地址
+				DialContext:           dialer.DialContext,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ResponseHeaderTimeout: 15 * time.Second,
+				MaxIdleConns:          10,
+				IdleConnTimeout:       30 * time.Second,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > config.AppConfig.RemoteFetchMaxRedirects {
+					return ErrRemoteTooManyHops
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return ErrRemoteURLInvalid
+				}
+				return nil
+			},
+		}
+	})
+	return s.client
+}
+
+// ParseRemoteURL 校验远程地址：仅允许 http / https 且包含主机名
+func ParseRemoteURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return nil, ErrRemoteURLInvalid
+	}
+	return u, nil
+}
+
+// Fetch 下载远程文件，总耗时不超过 REMOTE_FETCH_TIMEOUT，大小不超过 maxSize
+func (s *RemoteFetchService) Fetch(ctx context.Context, rawURL string, maxSize int64) (*RemoteFile, error) {
+	u, err := ParseRemoteURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.AppConfig.RemoteFetchTimeout)*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, ErrRemoteURLInvalid
+	}
+	req.Header.Set("Accept", "image/*")
+	req.Header.Set("User-Agent", "image-host-fetcher/1.0")
+
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, &RemoteStatusError{StatusCode: resp.StatusCode}
+	}
+	if resp.ContentLength > maxSize {
+		return nil, ErrRemoteTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, ErrRemoteTooLarge
+	}
+	return &RemoteFile{
+		Data:        data,
+		ContentType: resp.Header.Get("Content-Type"),
+		FileName:    remoteFileName(resp),
+		FinalURL:    resp.Request.URL.String(),
+	}, nil
+}
+
+// remoteFileName 文件名取自 Content-Disposition，其次为最终地址的路径末段
+func remoteFileName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := path.Base(strings.ReplaceAll(params["filename"], "\\", "/")); name != "" && name != "." && name != "/" {
+			return name
+		}
+	}
+	if name := path.Base(resp.Request.URL.Path); name != "" && name != "." && name != "/" {
+		return name
+	}
+	return "remote"
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"image-host/database"
+	"image-host/models"
+
+	"gorm.io/gorm"
+)
+
+// ImageFilter 图片检索条件，列表、搜索及后续批量操作共用
+type ImageFilter struct {
+	Uploader   string   // 精确匹配上传者；非管理员由控制器强制为本人
+	AlbumID    uint     // 限定相册（调用方需先校验相册归属）
+	Tags       []string // 需同时包含全部标签
+	Name       string   // original_name 子串
+	MimeType   string
+	Visibility string
+	MinSize    int64
+	MaxSize    int64
+	MinWidth   int
+	MaxWidth   int
+	MinHeight  int
+	MaxHeight  int
+	From       *time.Time // created_at >= From
+	To         *time.Time // created_at < To
+	Sort       string     // created_at | size | name | width | height | position
+	Desc       bool
+}
+
+// sortColumns 允许的排序字段
+var sortColumns = map[string]string{
+	"created_at": "images.created_at",
+	"size":       "images.file_size",
+	"name":       "images.original_name",
+	"width":      "images.width",
+	"height":     "images.height",
+	"position":   "album_images.position",
+}
+
+// ParseImageFilter 解析查询参数：
+// tag（可多次或逗号分隔）、q、mime_type、min_size/max_size、min_width/max_width、
+// min_height/max_height、visibility、from/to（YYYY-MM-DD 或 RFC3339，to 为日期时包含当天）、
+// uploader、sort（字段名，前缀 - 表示降序，默认 -created_at；按相册过滤时默认 position）
+func ParseImageFilter(q url.Values) (*ImageFilter, error) {
+	f := &ImageFilter{
+		Uploader: strings.TrimSpace(q.Get("uploader")),
+		Name:     strings.TrimSpace(q.Get("q")),
+		MimeType: strings.ToLower(strings.TrimSpace(q.Get("mime_type"))),
+	}
+
+	if v := strings.TrimSpace(q.Get("visibility")); v != "" {
+		if !models.ValidVisibility(v) {
+			return nil, fmt.Errorf("invalid visibility")
+		}
+		f.Visibility = v
+	}
+
+	tags, err := NormalizeTags(q["tag"])
+	if err != nil {
+		return nil, err
+	}
+	f.Tags = tags
+
+	if v := q.Get("album"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid album")
+		}
+		f.AlbumID = uint(id)
+	}
+
+	ints := []struct {
+		name string
+		dst  *int64
+	}{
+		{"min_size", &f.MinSize},
+		{"max_size", &f.MaxSize},
+	}
+	for _, it := range ints {
+		if v := q.Get(it.name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid %s", it.name)
+			}
+			*it.dst = n
+		}
+	}
+	dims := []struct {
+		name string
+		dst  *int
+	}{
+		{"min_width", &f.MinWidth},
+		{"max_width", &f.MaxWidth},
+		{"min_height", &f.MinHeight},
+		{"max_height", &f.MaxHeight},
+	}
+	for _, it := range dims {
+		if v := q.Get(it.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid %s", it.name)
+			}
+			*it.dst = n
+		}
+	}
+
+	if v := q.Get("from"); v != "" {
+		t, _, err := parseFilterTime(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from")
+		}
+		f.From = &t
+	}
+	if v := q.Get("to"); v != "" {
+		t, dateOnly, err := parseFilterTime(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to")
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		f.To = &t
+	}
+
+	sort := strings.TrimSpace(q.Get("sort"))
+	switch {
+	case sort == "" && f.AlbumID != 0:
+		sort = "position"
+	case sort == "":
+		sort = "-created_at"
+	}
+	if strings.HasPrefix(sort, "-") {
+		f.Desc = true
+		sort = sort[1:]
+	}
+	if _, ok := sortColumns[sort]; !ok || (sort == "position" && f.AlbumID == 0) {
+		return nil, fmt.Errorf("invalid sort")
+	}
+	f.Sort = sort
+	return f, nil
+}
+
+func parseFilterTime(v string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	return t, false, err
+}
+
+// Apply 将过滤条件应用到以 images 为主表的查询
+func (f *ImageFilter) Apply(db *gorm.DB) *gorm.DB {
+	if f.Uploader != "" {
+		db = db.Where("images.uploader = ?", f.Uploader)
+	}
+	if f.AlbumID != 0 {
+		db = db.Joins("JOIN album_images ON album_images.image_id = images.id").
+			Where("album_images.album_id = ?", f.AlbumID)
+	}
+	if len(f.Tags) > 0 {
+		sub := database.DB.Table("image_tags").
+			Select("image_tags.image_id").
+			Joins("JOIN tags ON tags.id = image_tags.tag_id").
+			Where("tags.name IN ?", f.Tags).
+			Group("image_tags.image_id").
+			Having("COUNT(DISTINCT image_tags.tag_id) = ?", len(f.Tags))
+		db = db.Where("images.id IN (?)", sub)
+	}
+	if f.Name != "" {
+		db = db.Where("images.original_name LIKE ?", "%"+escapeLike(f.Name)+"%")
+	}
+	if f.MimeType != "" {
+		db = db.Where("images.mime_type = ?", f.MimeType)
+	}
+	if f.Visibility != "" {
+		db = db.Where("images.visibility = ?", f.Visibility)
+	}
+	if f.MinSize > 0 {
+		db = db.Where("images.file_size >= ?", f.MinSize)
+	}
+	if f.MaxSize > 0 {
+		db = db.Where("images.file_size <= ?", f.MaxSize)
+	}
+	if f.MinWidth > 0 {
+		db = db.Where("images.width >= ?", f.MinWidth)
+	}
+	if f.MaxWidth > 0 {
+		db = db.Where("images.width <= ?", f.MaxWidth)
+	}
+	if f.MinHeight > 0 {
+		db = db.Where("images.height >= ?", f.MinHeight)
+	}
+	if f.MaxHeight > 0 {
+		db = db.Where("images.height <= ?", f.MaxHeight)
+	}
+	if f.From != nil {
+		db = db.Where("images.created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		db = db.Where("images.created_at < ?", *f.To)
+	}
+	return db
+}
+
+// Order 排序子句，以 id 作为次级排序保证结果稳定
+func (f *ImageFilter) Order() string {
+	dir := "ASC"
+	if f.Desc {
+		dir = "DESC"
+	}
+	return sortColumns[f.Sort] + " " + dir + ", images.id " + dir
+}
+
+// escapeLike 转义 LIKE 通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
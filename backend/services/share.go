@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+
+	"image-host/database"
+	"image-host/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrShareNotFound         = errors.New("share link not found")
+	ErrShareExpired          = errors.New("share link expired")
+	ErrSharePasswordRequired = errors.New("share link password required")
+	ErrSharePasswordInvalid  = errors.New("invalid share link password")
+	ErrShareLocked           = errors.New("too many failed share link password attempts")
+)
+
+// 分享链接密码错误次数限制：窗口内同一链接或同一 IP 失败次数达到上限后暂时拒绝尝试
+const (
+	shareAttemptWindow   = 15 * time.Minute
+	shareMaxSlugFailures = 10
+	shareMaxIPFailures   = 30
+)
+
+type ShareService struct {
+	attempts *attemptLimiter
+}
+
+var Shares = &ShareService{attempts: newAttemptLimiter(shareAttemptWindow)}
+
+func init() {
+	// 定期清理过期的失败记录
+	Jobs.Every("share-attempts-cleanup", 5*time.Minute, func(context.Context) { Shares.attempts.cleanup() })
+}
+
+// attemptLimiter 固定窗口的失败次数计数
+type attemptLimiter struct {
+	mu       sync.Mutex
+	window   time.Duration
+	failures map[string]*attemptInfo
+}
+
+type attemptInfo struct {
+	count     int
+	lastReset time.Time
+}
+
+func newAttemptLimiter(window time.Duration) *attemptLimiter {
+	return &attemptLimiter{window: window, failures: make(map[string]*attemptInfo)}
+}
+
+// locked key 在当前窗口内的失败次数是否已达到 max，返回距窗口结束的时间
+func (l *attemptLimiter) locked(key string, max int) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	info, ok := l.failures[key]
+	if !ok {
+		return false, 0
+	}
+	remaining := l.window - time.Since(info.lastReset)
+	if remaining <= 0 {
+		delete(l.failures, key)
+		return false, 0
+	}
+	return info.count >= max, remaining
+}
+
+// fail 记录一次失败
+func (l *attemptLimiter) fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	info, ok := l.failures[key]
+	if !ok || now.Sub(info.lastReset) >= l.window {
+		l.failures[key] = &attemptInfo{count: 1, lastReset: now}
+		return
+	}
+	info.count++
+}
+
+// reset 清除 key 的失败记录
+func (l *attemptLimiter) reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, key)
+}
+
+// cleanup 清理过期的失败记录
+func (l *attemptLimiter) cleanup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	for key, info := range l.failures {
+		if now.Sub(info.lastReset) >= l.window {
+			delete(l.failures, key)
+		}
+	}
+}
+
+// ShareLockedError 密码尝试次数过多，RetryAfter 后可重试
+type ShareLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ShareLockedError) Error() string { return ErrShareLocked.Error() }
+
+func (e *ShareLockedError) Unwrap() error { return ErrShareLocked }
+
+// Create 为图片创建分享链接，password 为空表示无需密码
+func (s *ShareService) Create(img *models.Image, owner, password string, expiresAt *time.Time, maxViews int) (*models.ShareLink, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	link := &models.ShareLink{
+		Slug:      base64.RawURLEncoding.EncodeToString(buf),
+		ImageID:   img.ID,
+		Owner:     owner,
+		ExpiresAt: expiresAt,
+		MaxViews:  maxViews,
+	}
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		link.PasswordHash = string(hash)
+	}
+	if err := database.DB.Create(link).Error; err != nil {
+		return nil, err
+	}
+	link.HasPassword = link.PasswordHash != ""
+	return link, nil
+}
+
+// Open 校验分享链接（存在、未过期、访问次数未用尽、密码正确），count 为 true 时计入一次访问，返回对应图片。
+// 密码错误按链接与客户端 IP 分别计数，任一达到上限后在窗口内返回 *ShareLockedError
+func (s *ShareService) Open(slug, password, ip string, count bool) (*models.ShareLink, *models.Image, error) {
+	var link models.ShareLink
+	if err := database.DB.Where("slug = ?", slug).First(&link).Error; err != nil {
+		return nil, nil, ErrShareNotFound
+	}
+	if link.ExpiresAt != nil && time.Now().After(*link.ExpiresAt) {
+		return nil, nil, ErrShareExpired
+	}
+	if link.MaxViews > 0 && link.Views >= link.MaxViews {
+		return nil, nil, ErrShareExpired
+	}
+	if link.PasswordHash != "" {
+		if password == "" {
+			return nil, nil, ErrSharePasswordRequired
+		}
+		slugKey, ipKey := "slug:"+slug, "ip:"+ip
+		if locked, retry := s.attempts.locked(slugKey, shareMaxSlugFailures); locked {
+			return nil, nil, &ShareLockedError{RetryAfter: retry}
+		}
+		if locked, retry := s.attempts.locked(ipKey, shareMaxIPFailures); locked {
+			return nil, nil, &ShareLockedError{RetryAfter: retry}
+		}
+		if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+			s.attempts.fail(slugKey)
+			s.attempts.fail(ipKey)
+			return nil, nil, ErrSharePasswordInvalid
+		}
+		// 密码正确只清除该链接的计数；IP 计数保留，避免用自己的链接重置对其他链接的猜测次数
+		s.attempts.reset(slugKey)
+	}
+
+	var img models.Image
+	if err := database.DB.First(&img, link.ImageID).Error; err != nil {
+		return nil, nil, ErrShareNotFound
+	}
+
+	if !count {
+		return &link, &img, nil
+	}
+	// 条件自增，并发访问时也不会超过 max_views
+	result := database.DB.Model(&models.ShareLink{}).
+		Where("id = ? AND (max_views = 0 OR views < max_views)", link.ID).
+		UpdateColumn("views", gorm.Expr("views + 1"))
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, ErrShareExpired
+	}
+	link.Views++
+	return &link, &img, nil
+}
+
+// Fill 填充 HasPassword
+func (s *ShareService) Fill(links []models.ShareLink) {
+	for i := range links {
+		links[i].HasPassword = links[i].PasswordHash != ""
+	}
+}
+
+// DetachImage 删除图片时清理其分享链接
+func (s *ShareService) DetachImage(imageID uint) error {
+	return database.DB.Where("image_id = ?", imageID).Delete(&models.ShareLink{}).Error
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"image-host/models"
+
+	"gorm.io/gorm"
+)
+
+// createShare 为新图片创建分享链接
+func createShare(t *testing.T, db *gorm.DB, password string, maxViews int) *models.ShareLink {
+	t.Helper()
+	if err := createQuotaImage("alice", 100)(db); err != nil {
+		t.Fatal(err)
+	}
+	var img models.Image
+	if err := db.Last(&img).Error; err != nil {
+		t.Fatal(err)
+	}
+	link, err := Shares.Create(&img, "alice", password, nil, maxViews)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return link
+}
+
+// useShareAttempts 替换失败计数，避免测试之间互相影响
+func useShareAttempts(t *testing.T) {
+	old := Shares.attempts
+	Shares.attempts = newAttemptLimiter(shareAttemptWindow)
+	t.Cleanup(func() { Shares.attempts = old })
+}
+
+func TestShareOpenCountsViews(t *testing.T) {
+	db := useTestDB(t)
+	useShareAttempts(t)
+	link := createShare(t, db, "", 2)
+
+	// 不计数的请求（HEAD、后续分段）不消耗访问次数
+	for i := 0; i < 3; i++ {
+		if _, _, err := Shares.Open(link.Slug, "", "1.2.3.4", false); err != nil {
+			t.Fatalf("uncounted open %d: %v", i, err)
+		}
+	}
+	for i := 1; i <= 2; i++ {
+		got, _, err := Shares.Open(link.Slug, "", "1.2.3.4", true)
+		if err != nil || got.Views != i {
+			t.Fatalf("counted open %d: views = %v, err = %v", i, got, err)
+		}
+	}
+	// 用尽后计数与不计数的请求均被拒绝
+	for _, count := range []bool{true, false} {
+		if _, _, err := Shares.Open(link.Slug, "", "1.2.3.4", count); !errors.Is(err, ErrShareExpired) {
+			t.Fatalf("count=%v after max views: err = %v", count, err)
+		}
+	}
+	if _, _, err := Shares.Open("missing", "", "1.2.3.4", true); !errors.Is(err, ErrShareNotFound) {
+		t.Fatalf("missing slug: err = %v", err)
+	}
+}
+
+func TestShareOpenConcurrentMaxViews(t *testing.T) {
+	db := useTestDB(t)
+	useShareAttempts(t)
+	link := createShare(t, db, "", 3)
+
+	const n = 10
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, _, errs[i] = Shares.Open(link.Slug, "", "1.2.3.4", true)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	ok := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			ok++
+		case !errors.Is(err, ErrShareExpired):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	var stored models.ShareLink
+	if err := db.First(&stored, link.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if ok != 3 || stored.Views != 3 {
+		t.Fatalf("%d of %d concurrent opens succeeded, views = %d; want 3", ok, n, stored.Views)
+	}
+}
+
+func TestShareOpenPasswordLimiter(t *testing.T) {
+	db := useTestDB(t)
+	useShareAttempts(t)
+	link := createShare(t, db, "secret", 0)
+
+	if _, _, err := Shares.Open(link.Slug, "", "1.1.1.1", true); !errors.Is(err, ErrSharePasswordRequired) {
+		t.Fatalf("no password: err = %v", err)
+	}
+	// 密码正确清除该链接的失败计数
+	for i := 0; i < shareMaxSlugFailures-1; i++ {
+		Shares.Open(link.Slug, "wrong", "1.1.1.1", true)
+	}
+	if _, _, err := Shares.Open(link.Slug, "secret", "1.1.1.1", true); err != nil {
+		t.Fatalf("correct password: %v", err)
+	}
+
+	// 同一链接失败次数达到上限后，即使换 IP、密码正确也被拒绝
+	for i := 0; i < shareMaxSlugFailures; i++ {
+		if _, _, err := Shares.Open(link.Slug, "wrong", "2.2.2.2", true); !errors.Is(err, ErrSharePasswordInvalid) {
+			t.Fatalf("attempt %d: err = %v", i, err)
+		}
+	}
+	var locked *ShareLockedError
+	if _, _, err := Shares.Open(link.Slug, "secret", "3.3.3.3", true); !errors.As(err, &locked) || locked.RetryAfter <= 0 {
+		t.Fatalf("after %d failures: err = %v", shareMaxSlugFailures, err)
+	}
+	// 不需要密码的链接不受影响
+	open := createShare(t, db, "", 0)
+	if _, _, err := Shares.Open(open.Slug, "", "2.2.2.2", true); err != nil {
+		t.Fatalf("link without password: %v", err)
+	}
+
+	// 同一 IP 在多个链接上累计失败达到上限后被拒绝，其他 IP 不受影响
+	useShareAttempts(t)
+	links := []*models.ShareLink{link, createShare(t, db, "other", 0), createShare(t, db, "other", 0)}
+	for i := 0; i < shareMaxIPFailures; i++ {
+		if _, _, err := Shares.Open(links[i%len(links)].Slug, "wrong", "4.4.4.4", true); !errors.Is(err, ErrSharePasswordInvalid) {
+			t.Fatalf("ip attempt %d: err = %v", i, err)
+		}
+	}
+	if _, _, err := Shares.Open(link.Slug, "wrong", "4.4.4.4", true); !errors.Is(err, ErrShareLocked) {
+		t.Fatalf("ip over limit: err = %v", err)
+	}
+	fresh := createShare(t, db, "fresh", 0)
+	if _, _, err := Shares.Open(fresh.Slug, "fresh", "4.4.4.4", true); !errors.Is(err, ErrShareLocked) {
+		t.Fatalf("ip over limit on another link: err = %v", err)
+	}
+	if _, _, err := Shares.Open(fresh.Slug, "fresh", "5.5.5.5", true); err != nil {
+		t.Fatalf("other ip: %v", err)
+	}
+}
+
+func TestAttemptLimiterWindow(t *testing.T) {
+	l := newAttemptLimiter(shareAttemptWindow)
+	l.fail("k")
+	l.fail("k")
+	if locked, _ := l.locked("k", 2); !locked {
+		t.Fatal("not locked at limit")
+	}
+	// 窗口结束后恢复
+	l.failures["k"].lastReset = l.failures["k"].lastReset.Add(-shareAttemptWindow)
+	if locked, _ := l.locked("k", 2); locked {
+		t.Fatal("still locked after window")
+	}
+	l.fail("old")
+	l.failures["old"].lastReset = l.failures["old"].lastReset.Add(-shareAttemptWindow)
+	l.fail("new")
+	l.cleanup()
+	if _, ok := l.failures["old"]; ok || l.failures["new"] == nil {
+		t.Fatalf("cleanup left %v", l.failures)
+	}
+}
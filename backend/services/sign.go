@@ -0,0 +1,79 @@
+package services
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"image-host/config"
+)
+
+var (
+	ErrSignatureInvalid = errors.New("invalid signature")
+	ErrSignatureExpired = errors.New("signature expired")
+)
+
+// signedTransformParams 参与签名的变换参数，签名后不可被篡改
+var signedTransformParams = []string{"fit", "fmt", "h", "preset", "q", "w"}
+
+// signingKey URL 签名密钥：优先 URL_SIGNING_KEY，否则使用 JWT_SECRET
+func signingKey() []byte {
+	if config.AppConfig.URLSigningKey != "" {
+		return []byte(config.AppConfig.URLSigningKey)
+	}
+	return []byte(config.AppConfig.JWTSecret)
+}
+
+// DeletionSignature 免登录删除链接的签名（ShareX 等工具使用）
+func DeletionSignature(uuid string) string {
+	return hex.EncodeToString(hmacSHA256(signingKey(), "delete:"+uuid))[:32]
+}
+
+// VerifyDeletionSignature 校验删除链接签名
+func VerifyDeletionSignature(uuid, sig string) bool {
+	return hmac.Equal([]byte(DeletionSignature(uuid)), []byte(sig))
+}
+
+// SignImageURL 生成 /img/:uuid 的签名查询参数：变换参数 + exp（unix 秒）+ sig
+func SignImageURL(uuid string, expires time.Time, transform url.Values) url.Values {
+	q := url.Values{}
+	for _, k := range signedTransformParams {
+		if v := transform.Get(k); v != "" {
+			q.Set(k, v)
+		}
+	}
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	q.Set("exp", exp)
+	q.Set("sig", imageSignature(uuid, exp, q))
+	return q
+}
+
+// VerifyImageSignature 校验签名 URL：签名覆盖 UUID、exp 与全部变换参数
+func VerifyImageSignature(uuid string, values url.Values) error {
+	exp := values.Get("exp")
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	if !hmac.Equal([]byte(imageSignature(uuid, exp, values)), []byte(values.Get("sig"))) {
+		return ErrSignatureInvalid
+	}
+	if time.Now().Unix() > expUnix {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+func imageSignature(uuid, exp string, values url.Values) string {
+	var b strings.Builder
+	b.WriteString("img:" + uuid + "\n" + exp)
+	for _, k := range signedTransformParams {
+		b.WriteString("\n" + k + "=" + values.Get(k))
+	}
+	return base64.RawURLEncoding.EncodeToString(hmacSHA256(signingKey(), b.String()))
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"image-host/config"
+)
+
+func setSigningConfig(t *testing.T, signingKey, jwtSecret string) {
+	old := config.AppConfig
+	config.AppConfig = &config.Config{URLSigningKey: signingKey, JWTSecret: jwtSecret}
+	t.Cleanup(func() { config.AppConfig = old })
+}
+
+const testSignUUID = "0b5b7f5e-8f0a-4c8e-9d43-1f5a2b7c9e01"
+
+func TestImageSignatureRoundTrip(t *testing.T) {
+	setSigningConfig(t, "url-key", "jwt-secret")
+	q := SignImageURL(testSignUUID, time.Now().Add(time.Hour), url.Values{"preset": {"thumb"}, "x": {"ignored"}})
+	if q.Get("x") != "" || q.Get("preset") != "thumb" {
+		t.Fatalf("signed params = %v", q)
+	}
+	if err := VerifyImageSignature(testSignUUID, q); err != nil {
+		t.Fatalf("VerifyImageSignature: %v", err)
+	}
+	// 未参与签名的参数可以附加
+	q.Set("utm", "x")
+	if err := VerifyImageSignature(testSignUUID, q); err != nil {
+		t.Fatalf("extra unsigned param: %v", err)
+	}
+}
+
+func TestImageSignatureExpired(t *testing.T) {
+	setSigningConfig(t, "url-key", "")
+	q := SignImageURL(testSignUUID, time.Now().Add(-time.Second), nil)
+	if err := VerifyImageSignature(testSignUUID, q); !errors.Is(err, ErrSignatureExpired) {
+		t.Fatalf("expired: err = %v", err)
+	}
+	// 延长已过期链接的 exp 会使签名失效
+	extended := url.Values{}
+	for k, v := range q {
+		extended[k] = v
+	}
+	extended.Set("exp", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	if err := VerifyImageSignature(testSignUUID, extended); !errors.Is(err, ErrSignatureInvalid) {
+		t.Fatalf("extended exp: err = %v", err)
+	}
+}
+
+func TestImageSignatureTampered(t *testing.T) {
+	setSigningConfig(t, "url-key", "")
+	exp := time.Now().Add(time.Hour)
+	q := SignImageURL(testSignUUID, exp, url.Values{"w": {"300"}})
+
+	tamper := func(key, value string) url.Values {
+		out := url.Values{}
+		for k, v := range q {
+			out[k] = v
+		}
+		if value == "" {
+			out.Del(key)
+		} else {
+			out.Set(key, value)
+		}
+		return out
+	}
+	cases := []struct {
+		name   string
+		uuid   string
+		values url.Values
+	}{
+		// 保留原 sig，修改其他字段
+		{"other uuid", "0b5b7f5e-8f0a-4c8e-9d43-1f5a2b7c9e02", q},
+		{"exp changed", testSignUUID, tamper("exp", strconv.FormatInt(exp.Unix()+1, 10))},
+		{"exp not a number", testSignUUID, tamper("exp", "tomorrow")},
+		{"exp missing", testSignUUID, tamper("exp", "")},
+		{"width changed", testSignUUID, tamper("w", "3000")},
+		{"width removed", testSignUUID, tamper("w", "")},
+		{"preset added", testSignUUID, tamper("preset", "thumb")},
+		// 常量时间比较：长度不同、前缀相同、大小写不同或为空的签名均拒绝
+		{"sig missing", testSignUUID, tamper("sig", "")},
+		{"sig truncated", testSignUUID, tamper("sig", q.Get("sig")[:len(q.Get("sig"))-1])},
+		{"sig extended", testSignUUID, tamper("sig", q.Get("sig")+"A")},
+		{"sig last char", testSignUUID, tamper("sig", flipLast(q.Get("sig")))},
+		{"sig upper case", testSignUUID, tamper("sig", strings.ToUpper(q.Get("sig")))},
+	}
+	for _, tc := range cases {
+		if err := VerifyImageSignature(tc.uuid, tc.values); !errors.Is(err, ErrSignatureInvalid) {
+			t.Errorf("%s: err = %v, want ErrSignatureInvalid", tc.name, err)
+		}
+	}
+
+	// 更换密钥后旧签名失效
+	config.AppConfig.URLSigningKey = "rotated"
+	if err := VerifyImageSignature(testSignUUID, q); !errors.Is(err, ErrSignatureInvalid) {
+		t.Fatalf("after key rotation: err = %v", err)
+	}
+}
+
+// flipLast 替换字符串最后一个字符
+func flipLast(s string) string {
+	last := byte('A')
+	if s[len(s)-1] == 'A' {
+		last = 'B'
+	}
+	return s[:len(s)-1] + string(last)
+}
+
+func TestSigningKeyFallback(t *testing.T) {
+	setSigningConfig(t, "", "jwt-secret")
+	withJWT := DeletionSignature(testSignUUID)
+	config.AppConfig.URLSigningKey = "url-key"
+	if DeletionSignature(testSignUUID) == withJWT {
+		t.Fatal("URL_SIGNING_KEY not used when set")
+	}
+	config.AppConfig.URLSigningKey = ""
+	if DeletionSignature(testSignUUID) != withJWT {
+		t.Fatal("JWT_SECRET fallback not stable")
+	}
+}
+
+func TestDeletionSignature(t *testing.T) {
+	setSigningConfig(t, "url-key", "")
+	sig := DeletionSignature(testSignUUID)
+	if len(sig) != 32 {
+		t.Fatalf("len(sig) = %d", len(sig))
+	}
+	if !VerifyDeletionSignature(testSignUUID, sig) {
+		t.Fatal("valid deletion signature rejected")
+	}
+	for _, bad := range []string{"", sig[:31], sig + "0", flipLast(sig), strings.ToUpper(sig)} {
+		if bad != sig && VerifyDeletionSignature(testSignUUID, bad) {
+			t.Errorf("VerifyDeletionSignature(%q) accepted", bad)
+		}
+	}
+	if VerifyDeletionSignature("0b5b7f5e-8f0a-4c8e-9d43-1f5a2b7c9e02", sig) {
+		t.Error("signature accepted for another uuid")
+	}
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"image-host/config"
+)
+
+// ErrObjectNotFound 存储中不存在指定对象
+var ErrObjectNotFound = errors.New("object not found")
+
+// StorageBackend 存储后端接口，R2Service 通过它读写实际文件
+type StorageBackend interface {
+	// Put 写入对象，key 形如 images/2025/01/02/<uuid>.png
+	Put(key string, data []byte, contentType string) error
+	// Get 读取对象，调用方负责关闭；不存在时返回 ErrObjectNotFound
+	Get(key string) (io.ReadCloser, error)
+	// Delete 删除对象，不存在时不报错
+	Delete(key string) error
+	// URL 返回对象的访问地址
+	URL(key string) string
+}
+
+// SeekableBackend 可按区间读取对象的存储后端：Open 返回的内容在 Seek 后按需读取，
+// 输出 Range 请求或大文件时不必整个对象读入内存
+type SeekableBackend interface {
+	Open(key string) (io.ReadSeekCloser, error)
+}
+
+// NewStorageBackend 根据配置创建存储后端
+func NewStorageBackend(cfg *config.Config) (StorageBackend, error) {
+	switch cfg.StorageDriver {
+	case "", "local":
+		return NewLocalStorage(cfg.UploadPath)
+	case "s3", "r2":
+		return NewS3Storage(S3Options{
+			Endpoint:        cfg.R2Endpoint,
+			AccountID:       cfg.R2AccountID,
+			Region:          cfg.R2Region,
+			Bucket:          cfg.R2BucketName,
+			AccessKeyID:     cfg.R2AccessKeyID,
+			SecretAccessKey: cfg.R2SecretAccessKey,
+			PublicURL:       cfg.R2PublicURL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.StorageDriver)
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage 本地磁盘存储，文件通过 /uploads 静态路由访问
+type LocalStorage struct {
+	root string
+}
+
+// NewLocalStorage 创建本地存储并确保根目录存在
+func NewLocalStorage(root string) (*LocalStorage, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %v", err)
+	}
+	return &LocalStorage{root: root}, nil
+}
+
+// path 将 key 转为本地路径，拒绝跳出根目录的 key
+func (l *LocalStorage) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid key: %s", key)
+	}
+	return filepath.Join(l.root, clean), nil
+}
+
+// Put 写入文件到本地
+func (l *LocalStorage) Put(key string, data []byte, contentType string) error {
+	localPath, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("failed to create local directory: %v", err)
+	}
+	if err := os.WriteFile(localPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write local file: %v", err)
+	}
+	return nil
+}
+
+// Get 打开本地文件
+func (l *LocalStorage) Get(key string) (io.ReadCloser, error) {
+	localPath, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(localPath)
+	if os.IsNotExist(err) {
+		return nil, ErrObjectNotFound
+	}
+	return f, err
+}
+
+// Delete 删除本地文件（忽略不存在错误）
+func (l *LocalStorage) Delete(key string) error {
+	localPath, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete local file: %v", err)
+	}
+	return nil
+}
+
+// URL 生成相对访问 URL，便于在任意域名/端口下工作
+func (l *LocalStorage) URL(key string) string {
+	return fmt.Sprintf("/uploads/%s", key)
+}
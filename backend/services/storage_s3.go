@@ -0,0 +1,306 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Options S3 兼容存储（Cloudflare R2、MinIO 等）连接参数
+type S3Options struct {
+	Endpoint        string // 例如 https://<account>.r2.cloudflarestorage.com 或 http://minio:9000
+	AccountID       string // 未配置 Endpoint 时用于推导 R2 地址
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PublicURL       string // 公开访问域名，为空时使用 Endpoint/Bucket
+}
+
+// S3Storage 基于 SigV4 签名的最小 S3 客户端，使用 path-style 寻址
+type S3Storage struct {
+	opts     S3Options
+	endpoint *url.URL
+	client   *http.Client
+	stream   *http.Client // Open 使用：响应体按客户端的下载速度读取，只限制等待响应头的时间
+}
+
+// NewS3Storage 创建 S3 兼容存储
+func NewS3Storage(opts S3Options) (*S3Storage, error) {
+	if opts.Endpoint == "" && opts.AccountID != "" {
+		opts.Endpoint = fmt.Sprintf("https://%s.r2.cloudflarestorage.com", opts.AccountID)
+	}
+	if opts.Endpoint == "" || opts.Bucket == "" {
+		return nil, fmt.Errorf("s3 storage requires endpoint and bucket")
+	}
+	if opts.AccessKeyID == "" || opts.SecretAccessKey == "" {
+		return nil, fmt.Errorf("s3 storage requires access key and secret")
+	}
+	if opts.Region == "" {
+		opts.Region = "auto"
+	}
+	u, err := url.Parse(strings.TrimRight(opts.Endpoint, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint: %s", opts.Endpoint)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 60 * time.Second
+	return &S3Storage{
+		opts:     opts,
+		endpoint: u,
+		client:   &http.Client{Timeout: 60 * time.Second},
+		stream:   &http.Client{Transport: transport},
+	}, nil
+}
+
+// Put 上传对象
+func (s *S3Storage) Put(key string, data []byte, contentType string) error {
+	req, err := s.newRequest(http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return s.responseError("put", resp)
+	}
+	return nil
+}
+
+// Get 下载对象
+func (s *S3Storage) Get(key string) (io.ReadCloser, error) {
+	req, err := s.newRequest(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %v", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrObjectNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, s.responseError("get", resp)
+	}
+	return resp.Body, nil
+}
+
+// Open 以可 Seek 的方式打开对象：先以 HEAD 取得大小，读取时从当前位置发起 Range 请求，
+// Seek 到其他位置后重新请求，只下载实际读取的部分
+func (s *S3Storage) Open(key string) (io.ReadSeekCloser, error) {
+	req, err := s.newRequest(http.MethodHead, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrObjectNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, s.responseError("head", resp)
+	}
+	if resp.ContentLength < 0 {
+		return nil, fmt.Errorf("s3 head failed: missing content length")
+	}
+	return &s3Object{s: s, key: key, size: resp.ContentLength}, nil
+}
+
+// s3Object Open 返回的对象内容，body 为从 offset 开始的 Range 响应
+type s3Object struct {
+	s      *S3Storage
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		if err := o.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	if err == io.EOF && o.offset < o.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// open 请求从 offset 到末尾的内容
+func (o *s3Object) open() error {
+	req, err := o.s.newRequest(http.MethodGet, o.key, nil)
+	if err != nil {
+		return err
+	}
+	want := http.StatusOK
+	if o.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", o.offset))
+		want = http.StatusPartialContent
+	}
+	resp, err := o.s.stream.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to get object: %v", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return ErrObjectNotFound
+	}
+	if resp.StatusCode != want {
+		defer resp.Body.Close()
+		return o.s.responseError("get", resp)
+	}
+	o.body = resp.Body
+	return nil
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.size
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("s3 object: negative position")
+	}
+	if offset != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = offset
+	return offset, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
+
+// Delete 删除对象（S3 对不存在的对象同样返回成功）
+func (s *S3Storage) Delete(key string) error {
+	req, err := s.newRequest(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return s.responseError("delete", resp)
+	}
+	return nil
+}
+
+// URL 返回对象公开地址
+func (s *S3Storage) URL(key string) string {
+	if s.opts.PublicURL != "" {
+		return strings.TrimRight(s.opts.PublicURL, "/") + "/" + key
+	}
+	return s.endpoint.String() + s.objectPath(key)
+}
+
+func (s *S3Storage) objectPath(key string) string {
+	segments := strings.Split(s.opts.Bucket+"/"+key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+func (s *S3Storage) responseError(op string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 %s failed: %s %s", op, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// newRequest 构造并签名请求（AWS Signature Version 4）
+func (s *S3Storage) newRequest(method, key string, body []byte) (*http.Request, error) {
+	path := s.objectPath(key)
+	req, err := http.NewRequest(method, s.endpoint.String()+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	shortDate := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		method,
+		path,
+		"",
+		"host:" + s.endpoint.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", shortDate, s.opts.Region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.opts.SecretAccessKey), shortDate)
+	signingKey = hmacSHA256(signingKey, s.opts.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.opts.AccessKeyID, scope, signedHeaders, signature,
+	))
+	return req, nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testBucket    = "images"
+	testRegion    = "us-east-1"
+)
+
+// fakeS3 path-style 的 S3 替身：独立校验 SigV4 签名与负载哈希，按 bucket/key 保存对象
+type fakeS3 struct {
+	t       *testing.T
+	secret  string
+	mu      sync.Mutex
+	objects map[string]fakeObject
+	// failWith 非 0 时所有请求返回该状态码
+	failWith int
+	// gets 记录每个 GET 请求的 Range 头
+	gets []string
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+func newFakeS3(t *testing.T, secret string) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{t: t, secret: secret, objects: map[string]fakeObject{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if code, msg := f.verify(r, body); code != 0 {
+		w.WriteHeader(code)
+		fmt.Fprintf(w, "<Error><Code>%s</Code></Error>", msg)
+		return
+	}
+	if f.failWith != 0 {
+		w.WriteHeader(f.failWith)
+		io.WriteString(w, "<Error><Code>InternalError</Code></Error>")
+		return
+	}
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if !ok || bucket != testBucket || key == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[key] = fakeObject{data: body, contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet, http.MethodHead:
+		obj, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				io.WriteString(w, "<Error><Code>NoSuchKey</Code></Error>")
+			}
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		if r.Method == http.MethodGet {
+			f.gets = append(f.gets, r.Header.Get("Range"))
+		}
+		// 与 S3 一致：支持 Range，HEAD 返回 Content-Length
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(obj.data))
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// getRanges 已收到的 GET 请求的 Range 头
+func (f *fakeS3) getRanges() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.gets...)
+}
+
+// verify 按 AWS SigV4 规范由收到的请求重新计算签名；返回非 0 状态码表示校验失败
+func (f *fakeS3) verify(r *http.Request, body []byte) (int, string) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		return http.StatusForbidden, "MissingSecurityHeader"
+	}
+	fields := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		fields[k] = v
+	}
+	cred := strings.Split(fields["Credential"], "/")
+	if len(cred) != 5 || cred[0] != testAccessKey || cred[3] != "s3" || cred[4] != "aws4_request" {
+		return http.StatusForbidden, "InvalidAccessKeyId"
+	}
+	shortDate, region := cred[1], cred[2]
+
+	payloadHash := r.Header.Get("x-amz-content-sha256")
+	sum := sha256.Sum256(body)
+	if payloadHash != hex.EncodeToString(sum[:]) {
+		return http.StatusBadRequest, "XAmzContentSHA256Mismatch"
+	}
+	amzDate := r.Header.Get("x-amz-date")
+	ts, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil || !strings.HasPrefix(amzDate, shortDate) || time.Since(ts).Abs() > 15*time.Minute {
+		return http.StatusForbidden, "RequestTimeTooSkewed"
+	}
+
+	var canonicalHeaders strings.Builder
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	for _, h := range signed {
+		v := r.Header.Get(h)
+		if h == "host" {
+			v = r.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	canonical := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		canonicalHeaders.String(),
+		fields["SignedHeaders"],
+		payloadHash,
+	}, "\n")
+	canonicalSum := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" +
+		strings.Join(cred[1:], "/") + "\n" + hex.EncodeToString(canonicalSum[:])
+
+	key := []byte("AWS4" + f.secret)
+	for _, part := range []string{shortDate, region, "s3", "aws4_request", stringToSign} {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(part))
+		key = h.Sum(nil)
+	}
+	if !hmac.Equal([]byte(hex.EncodeToString(key)), []byte(fields["Signature"])) {
+		return http.StatusForbidden, "SignatureDoesNotMatch"
+	}
+	return 0, ""
+}
+
+func newTestS3Storage(t *testing.T, endpoint, secret string) *S3Storage {
+	s, err := NewS3Storage(S3Options{
+		Endpoint:        endpoint,
+		Region:          testRegion,
+		Bucket:          testBucket,
+		AccessKeyID:     testAccessKey,
+		SecretAccessKey: secret,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	return s
+}
+
+func TestS3StoragePutGetDelete(t *testing.T) {
+	fake, srv := newFakeS3(t, testSecretKey)
+	s := newTestS3Storage(t, srv.URL, testSecretKey)
+
+	// 含空格与非 ASCII 字符的 key 需按段转义后参与签名
+	keys := []string{"images/2025/01/02/a.png", "images/2025/01/02/my photo 照片.jpg"}
+	for _, key := range keys {
+		data := []byte("content of " + key)
+		if err := s.Put(key, data, "image/png"); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+		if got := fake.objects[key]; string(got.data) != string(data) || got.contentType != "image/png" {
+			t.Fatalf("stored object %q = %q (%s)", key, got.data, got.contentType)
+		}
+
+		rc, err := s.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+		got, _ := io.ReadAll(rc)
+		rc.Close()
+		if string(got) != string(data) {
+			t.Fatalf("Get(%q) = %q, want %q", key, got, data)
+		}
+
+		if err := s.Delete(key); err != nil {
+			t.Fatalf("Delete(%q): %v", key, err)
+		}
+		if _, ok := fake.objects[key]; ok {
+			t.Fatalf("object %q still exists after Delete", key)
+		}
+	}
+
+	// 空对象的负载哈希为空串的 SHA-256
+	if err := s.Put("images/empty.png", nil, ""); err != nil {
+		t.Fatalf("Put empty: %v", err)
+	}
+}
+
+func TestS3StorageErrors(t *testing.T) {
+	fake, srv := newFakeS3(t, testSecretKey)
+	s := newTestS3Storage(t, srv.URL, testSecretKey)
+
+	if _, err := s.Get("images/missing.png"); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("Get missing: err = %v, want ErrObjectNotFound", err)
+	}
+	if err := s.Delete("images/missing.png"); err != nil {
+		t.Fatalf("Delete missing: %v", err)
+	}
+
+	// 签名错误
+	bad := newTestS3Storage(t, srv.URL, "wrong-secret")
+	err := bad.Put("images/a.png", []byte("x"), "image/png")
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Fatalf("Put with wrong secret: err = %v", err)
+	}
+	if _, err := bad.Get("images/a.png"); err == nil || errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("Get with wrong secret: err = %v", err)
+	}
+
+	// 服务端错误
+	fake.failWith = http.StatusInternalServerError
+	for name, err := range map[string]error{
+		"put":    s.Put("images/a.png", []byte("x"), "image/png"),
+		"delete": s.Delete("images/a.png"),
+	} {
+		if err == nil || !strings.Contains(err.Error(), "s3 "+name+" failed: 500") {
+			t.Fatalf("%s on server error: err = %v", name, err)
+		}
+	}
+	if _, err := s.Get("images/a.png"); err == nil || !strings.Contains(err.Error(), "s3 get failed: 500") {
+		t.Fatalf("get on server error: err = %v", err)
+	}
+
+	// 连接失败
+	srv.Close()
+	if err := s.Put("images/a.png", []byte("x"), "image/png"); err == nil || !strings.Contains(err.Error(), "failed to upload object") {
+		t.Fatalf("Put on closed server: err = %v", err)
+	}
+}
+
+func TestS3StorageOpen(t *testing.T) {
+	fake, srv := newFakeS3(t, testSecretKey)
+	s := newTestS3Storage(t, srv.URL, testSecretKey)
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	if err := s.Put("images/a.png", data, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Open("images/missing.png"); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("Open missing: err = %v", err)
+	}
+
+	// 未读取前不下载；完整读取为一次不带 Range 的请求
+	obj, err := s.Open("images/a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gets := fake.getRanges(); len(gets) != 0 {
+		t.Fatalf("Open issued GET requests: %q", gets)
+	}
+	got, err := io.ReadAll(obj)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("ReadAll: %d bytes, err = %v", len(got), err)
+	}
+	if gets := fake.getRanges(); len(gets) != 1 || gets[0] != "" {
+		t.Fatalf("full read requests = %q", gets)
+	}
+	obj.Close()
+
+	// 经 http.ServeContent 输出 Range 请求（与 serveContent 一样预先设置 Content-Type，不做内容嗅探）：
+	// 只发起一次从区间起点开始的 Range 请求，读取所需字节后关闭
+	fake.mu.Lock()
+	fake.gets = nil
+	fake.mu.Unlock()
+	obj, err = s.Open("images/a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer obj.Close()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Range", "bytes=50000-50009")
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Type", "image/png")
+	http.ServeContent(w, req, "", time.Time{}, obj)
+	if w.Code != http.StatusPartialContent || w.Body.String() != string(data[50000:50010]) {
+		t.Fatalf("range response: %d %q", w.Code, w.Body.String())
+	}
+	if gets := fake.getRanges(); len(gets) != 1 || gets[0] != "bytes=50000-" {
+		t.Fatalf("range requests = %q", gets)
+	}
+	obj.Close()
+
+	// Seek 到其他位置后重新请求
+	fake.mu.Lock()
+	fake.gets = nil
+	fake.mu.Unlock()
+	obj, _ = s.Open("images/a.png")
+	defer obj.Close()
+	buf := make([]byte, 4)
+	if _, err := obj.Seek(-4, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(obj, buf); err != nil || string(buf) != "6789" {
+		t.Fatalf("read at end: %q, err = %v", buf, err)
+	}
+	if n, err := obj.Read(buf); n != 0 || err != io.EOF {
+		t.Fatalf("read past end: %d, %v", n, err)
+	}
+	if _, err := obj.Seek(3, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(obj, buf); err != nil || string(buf) != "3456" {
+		t.Fatalf("read after seek: %q, err = %v", buf, err)
+	}
+	if gets, want := fake.getRanges(), []string{"bytes=99996-", "bytes=3-"}; fmt.Sprint(gets) != fmt.Sprint(want) {
+		t.Fatalf("requests = %q, want %q", gets, want)
+	}
+	if _, err := obj.Seek(-1, io.SeekStart); err == nil {
+		t.Fatal("negative seek accepted")
+	}
+}
+
+func TestS3StorageURL(t *testing.T) {
+	s := newTestS3Storage(t, "http://minio:9000/", testSecretKey)
+	if got, want := s.URL("images/a b.png"), "http://minio:9000/images/images/a%20b.png"; got != want {
+		t.Fatalf("URL = %q, want %q", got, want)
+	}
+	s.opts.PublicURL = "https://cdn.example.com/"
+	if got, want := s.URL("images/a.png"), "https://cdn.example.com/images/a.png"; got != want {
+		t.Fatalf("URL with public url = %q, want %q", got, want)
+	}
+}
+
+func TestNewS3StorageValidation(t *testing.T) {
+	if _, err := NewS3Storage(S3Options{Bucket: "b", AccessKeyID: "k", SecretAccessKey: "s"}); err == nil {
+		t.Fatal("expected error without endpoint")
+	}
+	if _, err := NewS3Storage(S3Options{Endpoint: "http://minio:9000", Bucket: "b"}); err == nil {
+		t.Fatal("expected error without credentials")
+	}
+	s, err := NewS3Storage(S3Options{AccountID: "acc", Bucket: "b", AccessKeyID: "k", SecretAccessKey: "s"})
+	if err != nil {
+		t.Fatalf("NewS3Storage with account id: %v", err)
+	}
+	if s.endpoint.Host != "acc.r2.cloudflarestorage.com" || s.opts.Region != "auto" {
+		t.Fatalf("derived endpoint %q region %q", s.endpoint.Host, s.opts.Region)
+	}
+}
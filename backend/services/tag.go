@@ -0,0 +1,157 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"image-host/database"
+	"image-host/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxTagsPerImage 单张图片的标签上限
+const MaxTagsPerImage = 32
+
+type TagService struct{}
+
+var Tags = &TagService{}
+
+// TagCount 标签及其使用次数
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// NormalizeTags 去除首尾空白、转小写并去重；接受数组或逗号分隔字符串拆分后的结果
+func NormalizeTags(names []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, raw := range names {
+		for _, n := range strings.Split(raw, ",") {
+			n = strings.ToLower(strings.TrimSpace(n))
+			if n == "" || seen[n] {
+				continue
+			}
+			if utf8.RuneCountInString(n) > 64 {
+				return nil, fmt.Errorf("tag too long: %s", n)
+			}
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	if len(out) > MaxTagsPerImage {
+		return nil, fmt.Errorf("too many tags (max %d)", MaxTagsPerImage)
+	}
+	return out, nil
+}
+
+// ensure 查找或创建标签记录，返回 ID 列表
+func (s *TagService) ensure(tx *gorm.DB, names []string) ([]uint, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	rows := make([]models.Tag, 0, len(names))
+	for _, n := range names {
+		rows = append(rows, models.Tag{Name: n})
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	var ids []uint
+	if err := tx.Model(&models.Tag{}).Where("name IN ?", names).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Set 用给定标签替换图片的全部标签
+func (s *TagService) Set(imageID uint, names []string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("image_id = ?", imageID).Delete(&models.ImageTag{}).Error; err != nil {
+			return err
+		}
+		return s.add(tx, []uint{imageID}, names)
+	})
+}
+
+// Add 为多张图片追加标签，已有的保持不变
+func (s *TagService) Add(imageIDs []uint, names []string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		return s.add(tx, imageIDs, names)
+	})
+}
+
+func (s *TagService) add(tx *gorm.DB, imageIDs []uint, names []string) error {
+	tagIDs, err := s.ensure(tx, names)
+	if err != nil || len(tagIDs) == 0 || len(imageIDs) == 0 {
+		return err
+	}
+	links := make([]models.ImageTag, 0, len(imageIDs)*len(tagIDs))
+	for _, img := range imageIDs {
+		for _, tag := range tagIDs {
+			links = append(links, models.ImageTag{ImageID: img, TagID: tag})
+		}
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+}
+
+// Remove 从多张图片移除指定标签
+func (s *TagService) Remove(imageIDs []uint, names []string) error {
+	if len(imageIDs) == 0 || len(names) == 0 {
+		return nil
+	}
+	return database.DB.
+		Where("image_id IN ? AND tag_id IN (?)", imageIDs,
+			database.DB.Model(&models.Tag{}).Select("id").Where("name IN ?", names)).
+		Delete(&models.ImageTag{}).Error
+}
+
+// DetachImage 删除图片时清理其标签关系
+func (s *TagService) DetachImage(imageID uint) error {
+	return database.DB.Where("image_id = ?", imageID).Delete(&models.ImageTag{}).Error
+}
+
+// Fill 为图片列表填充 Tags 字段
+func (s *TagService) Fill(images []models.Image) {
+	if len(images) == 0 {
+		return
+	}
+	ids := make([]uint, len(images))
+	for i := range images {
+		ids[i] = images[i].ID
+	}
+	var rows []struct {
+		ImageID uint
+		Name    string
+	}
+	database.DB.Table("image_tags").
+		Select("image_tags.image_id, tags.name").
+		Joins("JOIN tags ON tags.id = image_tags.tag_id").
+		Where("image_tags.image_id IN ?", ids).
+		Order("tags.name ASC").
+		Scan(&rows)
+	byImage := map[uint][]string{}
+	for _, r := range rows {
+		byImage[r.ImageID] = append(byImage[r.ImageID], r.Name)
+	}
+	for i := range images {
+		images[i].Tags = byImage[images[i].ID]
+	}
+}
+
+// Counts 统计标签使用次数；uploader 非空时仅统计该用户的图片
+func (s *TagService) Counts(uploader string) ([]TagCount, error) {
+	q := database.DB.Table("image_tags").
+		Select("tags.name AS name, COUNT(*) AS count").
+		Joins("JOIN tags ON tags.id = image_tags.tag_id").
+		Joins("JOIN images ON images.id = image_tags.image_id AND images.deleted_at IS NULL")
+	if uploader != "" {
+		q = q.Where("images.uploader = ?", uploader)
+	}
+	var out []TagCount
+	err := q.Group("tags.name").Order("count DESC, name ASC").Scan(&out).Error
+	return out, err
+}
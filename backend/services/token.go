@@ -0,0 +1,117 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"image-host/database"
+	"image-host/models"
+
+	"gorm.io/gorm"
+)
+
+// APITokenPrefix API token 明文前缀，用于与 JWT 区分
+const APITokenPrefix = "ih_"
+
+var (
+	ErrInvalidAPIToken = errors.New("invalid api token")
+	ErrAPITokenExpired = errors.New("api token expired")
+)
+
+type TokenService struct{}
+
+var Tokens = &TokenService{}
+
+// NormalizeScopes 校验并去重权限范围，返回逗号分隔形式
+func NormalizeScopes(scopes []string) (string, error) {
+	seen := map[string]bool{}
+	var out []string
+	for _, s := range scopes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" || seen[s] {
+			continue
+		}
+		if !models.ValidScope(s) {
+			return "", fmt.Errorf("invalid scope: %s", s)
+		}
+		seen[s] = true
+		out = append(out, s)
+	}
+	if len(out) == 0 {
+		return "", fmt.Errorf("at least one scope is required")
+	}
+	return strings.Join(out, ","), nil
+}
+
+// HasScope 判断 token 是否包含指定权限
+func HasScope(scopes, scope string) bool {
+	for _, s := range strings.Split(scopes, ",") {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Create 生成新 token，明文仅在创建时返回一次
+func (s *TokenService) Create(username, name, scopes string, expiresAt *time.Time) (string, *models.APIToken, error) {
+	return s.create(database.DB, username, name, scopes, expiresAt)
+}
+
+// Rotate 吊销该用户同名的 token 并生成新 token（如工具配置文件重复下载时只保留最新一个）
+func (s *TokenService) Rotate(username, name, scopes string) (string, *models.APIToken, error) {
+	var plain string
+	var t *models.APIToken
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("username = ? AND name = ?", username, name).Delete(&models.APIToken{}).Error; err != nil {
+			return err
+		}
+		var err error
+		plain, t, err = s.create(tx, username, name, scopes, nil)
+		return err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return plain, t, nil
+}
+
+func (s *TokenService) create(db *gorm.DB, username, name, scopes string, expiresAt *time.Time) (string, *models.APIToken, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	plain := APITokenPrefix + hex.EncodeToString(buf)
+	t := &models.APIToken{
+		Username:  username,
+		Name:      name,
+		TokenHash: sha256Hex([]byte(plain)),
+		Prefix:    plain[:len(APITokenPrefix)+6],
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := db.Create(t).Error; err != nil {
+		return "", nil, err
+	}
+	return plain, t, nil
+}
+
+// Authenticate 校验明文 token，成功时异步记录最近使用时间
+func (s *TokenService) Authenticate(plain string) (*models.APIToken, error) {
+	var t models.APIToken
+	if err := database.DB.Where("token_hash = ?", sha256Hex([]byte(plain))).First(&t).Error; err != nil {
+		return nil, ErrInvalidAPIToken
+	}
+	now := time.Now()
+	if t.ExpiresAt != nil && now.After(*t.ExpiresAt) {
+		return nil, ErrAPITokenExpired
+	}
+	Jobs.Go(func() {
+		database.DB.Model(&models.APIToken{}).Where("id = ?", t.ID).Update("last_used_at", now)
+	})
+	return &t, nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"image-host/models"
+)
+
+func TestTokenRotate(t *testing.T) {
+	db := useTestDB(t)
+	// Authenticate 异步更新最近使用时间，测试结束前等待其完成
+	oldJobs := Jobs
+	Jobs = NewJobManager()
+	t.Cleanup(func() {
+		Jobs.Shutdown(context.Background())
+		Jobs = oldJobs
+	})
+	scopes := models.ScopeUpload + "," + models.ScopeDelete
+
+	first, _, err := Tokens.Rotate("alice", "sharex config", scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := Tokens.Rotate("alice", "picgo config", scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobs, _, err := Tokens.Rotate("bob", "sharex config", scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manual, _, err := Tokens.Create("alice", "cli", models.ScopeRead, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, tok, err := Tokens.Rotate("alice", "sharex config", scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first || tok.Scopes != scopes {
+		t.Fatalf("rotated token = %q, scopes %q", second, tok.Scopes)
+	}
+	// 旧 token 吊销；其他工具、其他用户与其他名称的 token 不受影响
+	if _, err := Tokens.Authenticate(first); !errors.Is(err, ErrInvalidAPIToken) {
+		t.Fatalf("old token: err = %v", err)
+	}
+	for _, plain := range []string{second, other, bobs, manual} {
+		if _, err := Tokens.Authenticate(plain); err != nil {
+			t.Errorf("Authenticate(%s...): %v", plain[:9], err)
+		}
+	}
+	var count int64
+	db.Model(&models.APIToken{}).Where("username = ? AND name = ?", "alice", "sharex config").Count(&count)
+	if count != 1 {
+		t.Fatalf("%d sharex tokens for alice, want 1", count)
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"image-host/database"
+	"image-host/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// trafficKey 访问统计的聚合维度
+type trafficKey struct {
+	date        string
+	imageID     uint
+	refererHost string
+}
+
+type trafficCounter struct {
+	views int64
+	bytes int64
+}
+
+// TrafficService 图片访问统计；输出路径只做内存累加，由 StartFlushJob 定期写入聚合表，不阻塞图片输出
+type TrafficService struct {
+	mu      sync.Mutex
+	pending map[trafficKey]*trafficCounter
+}
+
+var Traffic = &TrafficService{pending: map[trafficKey]*trafficCounter{}}
+
+// TrafficDay 单日访问量与流量
+type TrafficDay struct {
+	Date  string `json:"date"`
+	Views int64  `json:"views"`
+	Bytes int64  `json:"bytes"`
+}
+
+// TrafficReferer 来源域名排行
+type TrafficReferer struct {
+	RefererHost string `json:"referer_host"`
+	Views       int64  `json:"views"`
+	Bytes       int64  `json:"bytes"`
+}
+
+// TrafficImage 图片排行
+type TrafficImage struct {
+	ImageID      uint   `json:"image_id"`
+	UUID         string `json:"uuid"`
+	OriginalName string `json:"original_name"`
+	Uploader     string `json:"uploader"`
+	Views        int64  `json:"views"`
+	Bytes        int64  `json:"bytes"`
+}
+
+// RefererHost 从 Referer 头提取来源域名，无来源时为 (direct)
+func RefererHost(referer string) string {
+	if referer == "" {
+		return "(direct)"
+	}
+	u, err := url.Parse(referer)
+	if err != nil || u.Hostname() == "" {
+		return "(invalid)"
+	}
+	host := strings.ToLower(u.Hostname())
+	if len(host) > 255 {
+		host = host[:255]
+	}
+	return host
+}
+
+// Record 记录一次输出；view 为 false 时仅计流量（如 Range 续传）
+func (s *TrafficService) Record(imageID uint, refererHost string, bytes int64, view bool) {
+	key := trafficKey{date: time.Now().Format("2006-01-02"), imageID: imageID, refererHost: refererHost}
+	s.mu.Lock()
+	counter := s.pending[key]
+	if counter == nil {
+		counter = &trafficCounter{}
+		s.pending[key] = counter
+	}
+	if view {
+		counter.views++
+	}
+	counter.bytes += bytes
+	s.mu.Unlock()
+}
+
+// Flush 将内存中的计数写入 image_traffic 与 referer_traffic；写入失败的计数放回内存待下次写入，
+// 其余计数照常写入，返回最后一个错误
+func (s *TrafficService) Flush() error {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = map[trafficKey]*trafficCounter{}
+	s.mu.Unlock()
+
+	var lastErr error
+	now := time.Now()
+	for k, n := range pending {
+		date, _ := time.ParseInLocation("2006-01-02", k.date, time.Local)
+		increment := clause.Assignments(map[string]interface{}{
+			"views":      gorm.Expr("views + ?", n.views),
+			"bytes":      gorm.Expr("bytes + ?", n.bytes),
+			"updated_at": now,
+		})
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "date"}, {Name: "image_id"}},
+				DoUpdates: increment,
+			}).Create(&models.ImageTraffic{Date: date, ImageID: k.imageID, Views: n.views, Bytes: n.bytes}).Error; err != nil {
+				return err
+			}
+			return tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "date"}, {Name: "image_id"}, {Name: "referer_host"}},
+				DoUpdates: increment,
+			}).Create(&models.RefererTraffic{Date: date, ImageID: k.imageID, RefererHost: k.refererHost, Views: n.views, Bytes: n.bytes}).Error
+		})
+		if err != nil {
+			s.requeue(k, n)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// requeue 将未写入的计数合并回内存
+func (s *TrafficService) requeue(k trafficKey, n *trafficCounter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if counter := s.pending[k]; counter != nil {
+		counter.views += n.views
+		counter.bytes += n.bytes
+		return
+	}
+	s.pending[k] = n
+}
+
+// StartFlushJob 每分钟写入一次访问统计，退出时写入剩余计数
+func (s *TrafficService) StartFlushJob() {
+	flush := func() error {
+		if err := s.Flush(); err != nil {
+			log.Printf("Failed to flush traffic stats: %v", err)
+			return err
+		}
+		return nil
+	}
+	Jobs.Every("traffic-flush", time.Minute, func(context.Context) { _ = flush() })
+	Jobs.OnStop("traffic-flush", flush)
+}
+
+// DetachImage 删除图片时清理其访问统计
+func (s *TrafficService) DetachImage(imageID uint) error {
+	if err := database.DB.Where("image_id = ?", imageID).Delete(&models.ImageTraffic{}).Error; err != nil {
+		return err
+	}
+	return database.DB.Where("image_id = ?", imageID).Delete(&models.RefererTraffic{}).Error
+}
+
+// TrafficScope 统计范围：ImageID 非零时限定单张图片，Uploader 非空时限定该用户的图片
+type TrafficScope struct {
+	ImageID  uint
+	Uploader string
+	Days     int
+}
+
+func (sc TrafficScope) apply(db *gorm.DB, table string) *gorm.DB {
+	db = db.Where(table+".date >= ?", time.Now().AddDate(0, 0, -sc.Days+1).Format("2006-01-02"))
+	if sc.ImageID != 0 {
+		db = db.Where(table+".image_id = ?", sc.ImageID)
+	}
+	if sc.Uploader != "" {
+		db = db.Joins("JOIN images ON images.id = "+table+".image_id").Where("images.uploader = ?", sc.Uploader)
+	}
+	return db
+}
+
+// Daily 按天汇总
+func (s *TrafficService) Daily(sc TrafficScope) ([]TrafficDay, error) {
+	var rows []struct {
+		Date  time.Time
+		Views int64
+		Bytes int64
+	}
+	err := sc.apply(database.DB.Model(&models.ImageTraffic{}), "image_traffic").
+		Select("image_traffic.date AS date, SUM(image_traffic.views) AS views, SUM(image_traffic.bytes) AS bytes").
+		Group("image_traffic.date").Order("image_traffic.date ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make([]TrafficDay, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, TrafficDay{Date: r.Date.Format("2006-01-02"), Views: r.Views, Bytes: r.Bytes})
+	}
+	return out, nil
+}
+
+// TopReferers 来源域名排行
+func (s *TrafficService) TopReferers(sc TrafficScope, limit int) ([]TrafficReferer, error) {
+	var out []TrafficReferer
+	err := sc.apply(database.DB.Model(&models.RefererTraffic{}), "referer_traffic").
+		Select("referer_traffic.referer_host AS referer_host, SUM(referer_traffic.views) AS views, SUM(referer_traffic.bytes) AS bytes").
+		Group("referer_traffic.referer_host").Order("views DESC").Limit(limit).
+		Scan(&out).Error
+	return out, err
+}
+
+// TopImages 图片排行（按访问量）
+func (s *TrafficService) TopImages(sc TrafficScope, limit int) ([]TrafficImage, error) {
+	var out []TrafficImage
+	q := database.DB.Model(&models.ImageTraffic{})
+	if sc.Uploader == "" {
+		q = q.Joins("JOIN images ON images.id = image_traffic.image_id")
+	}
+	err := sc.apply(q, "image_traffic").
+		Select("image_traffic.image_id AS image_id, images.uuid AS uuid, images.original_name AS original_name, " +
+			"images.uploader AS uploader, SUM(image_traffic.views) AS views, SUM(image_traffic.bytes) AS bytes").
+		Group("image_traffic.image_id, images.uuid, images.original_name, images.uploader").
+		Order("views DESC").Limit(limit).
+		Scan(&out).Error
+	return out, err
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"image-host/config"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp"
+)
+
+// 变换参数上限
+const (
+	maxTransformDimension = 4096
+	defaultTransformQ     = 85
+)
+
+// ErrTransformNotAllowed 请求的参数组合不在预设白名单中
+var ErrTransformNotAllowed = errors.New("transform not allowed")
+
+// TransformOptions 图片变换参数
+type TransformOptions struct {
+	Width   int    // 0 表示按比例
+	Height  int    // 0 表示按比例
+	Fit     string // contain（默认，等比缩放至框内）/ cover（裁剪填满）/ fill（拉伸）
+	Format  string // jpeg / png / gif / webp，空表示保持原格式
+	Quality int    // 1-100，仅 JPEG 生效
+}
+
+// IsZero 是否未指定任何变换
+func (o TransformOptions) IsZero() bool {
+	return o == TransformOptions{}
+}
+
+// normalize 填充默认值，使等价请求得到相同的缓存 key 与白名单比较结果
+func (o TransformOptions) normalize() TransformOptions {
+	if o.Fit == "" {
+		o.Fit = "contain"
+	}
+	if o.Quality == 0 {
+		o.Quality = defaultTransformQ
+	}
+	return o
+}
+
+// ParseTransformOptions 从查询参数解析 w/h/fit/fmt/q
+func ParseTransformOptions(values url.Values) (TransformOptions, error) {
+	var o TransformOptions
+	var err error
+	if v := values.Get("w"); v != "" {
+		if o.Width, err = strconv.Atoi(v); err != nil || o.Width < 1 || o.Width > maxTransformDimension {
+			return o, fmt.Errorf("invalid width: %s", v)
+		}
+	}
+	if v := values.Get("h"); v != "" {
+		if o.Height, err = strconv.Atoi(v); err != nil || o.Height < 1 || o.Height > maxTransformDimension {
+			return o, fmt.Errorf("invalid height: %s", v)
+		}
+	}
+	if v := strings.ToLower(values.Get("fit")); v != "" {
+		switch v {
+		case "contain", "cover", "fill":
+			o.Fit = v
+		default:
+			return o, fmt.Errorf("invalid fit: %s", v)
+		}
+	}
+	if v := strings.ToLower(values.Get("fmt")); v != "" {
+		if v == "jpg" {
+			v = "jpeg"
+		}
+		switch v {
+		case "jpeg", "png", "gif", "webp":
+			o.Format = v
+		default:
+			return o, fmt.Errorf("invalid format: %s", v)
+		}
+	}
+	if v := values.Get("q"); v != "" {
+		if o.Quality, err = strconv.Atoi(v); err != nil || o.Quality < 1 || o.Quality > 100 {
+			return o, fmt.Errorf("invalid quality: %s", v)
+		}
+	}
+	if (o.Fit == "cover" || o.Fit == "fill") && (o.Width == 0 || o.Height == 0) {
+		return o, fmt.Errorf("fit=%s requires both w and h", o.Fit)
+	}
+	return o, nil
+}
+
+// TransformService 按预设对存储中的图片做缩放/裁剪/转码，并缓存结果
+type TransformService struct {
+	presets map[string]TransformOptions
+	cache   *VariantCache
+
+	// 同一缓存 key 的并发未命中只生成一次，其余请求等待并共享结果
+	flightMu sync.Mutex
+	flights  map[string]*variantFlight
+}
+
+// variantFlight 进行中的变换
+type variantFlight struct {
+	done     chan struct{}
+	data     []byte
+	mimeType string
+	err      error
+}
+
+var Transform *TransformService
+
+// InitTransformService 解析预设并初始化变换缓存
+func InitTransformService() {
+	presets, err := parseTransformPresets(config.AppConfig.TransformPresets)
+	if err != nil {
+		log.Fatal("Invalid TRANSFORM_PRESETS:", err)
+	}
+	cache, err := NewVariantCache(config.AppConfig.TransformCachePath, config.AppConfig.TransformCacheMaxBytes)
+	if err != nil {
+		log.Fatal("Failed to initialize transform cache:", err)
+	}
+	Transform = &TransformService{presets: presets, cache: cache, flights: map[string]*variantFlight{}}
+}
+
+// parseTransformPresets 解析 name:w=300&h=300&fit=cover;name2:w=640
+func parseTransformPresets(spec string) (map[string]TransformOptions, error) {
+	presets := make(map[string]TransformOptions)
+	for _, item := range strings.Split(spec, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, query, ok := strings.Cut(item, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid preset: %s", item)
+		}
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid preset %s: %v", name, err)
+		}
+		opts, err := ParseTransformOptions(values)
+		if err != nil {
+			return nil, fmt.Errorf("invalid preset %s: %v", name, err)
+		}
+		presets[strings.TrimSpace(name)] = opts.normalize()
+	}
+	return presets, nil
+}
+
+// Resolve 将请求参数解析为允许的变换：?preset=name，或与某个预设完全一致的 w/h/fit/fmt/q
+func (s *TransformService) Resolve(values url.Values) (TransformOptions, error) {
+	if name := values.Get("preset"); name != "" {
+		opts, ok := s.presets[name]
+		if !ok {
+			return TransformOptions{}, ErrTransformNotAllowed
+		}
+		return opts, nil
+	}
+	opts, err := ParseTransformOptions(values)
+	if err != nil || opts.IsZero() {
+		return opts, err
+	}
+	opts = opts.normalize()
+	for _, preset := range s.presets {
+		if preset == opts {
+			return opts, nil
+		}
+	}
+	return TransformOptions{}, ErrTransformNotAllowed
+}
+
+// Presets 返回可用预设
+func (s *TransformService) Presets() map[string]TransformOptions {
+	return s.presets
+}
+
+// Variant 返回指定图片的变换结果及其 MIME 类型，优先读取缓存
+func (s *TransformService) Variant(key string, opts TransformOptions) ([]byte, string, error) {
+	cacheKey := variantCacheKey(key, opts)
+	if data, ok := s.cache.Get(cacheKey); ok {
+		return data, http.DetectContentType(data), nil
+	}
+
+	s.flightMu.Lock()
+	if f, ok := s.flights[cacheKey]; ok {
+		s.flightMu.Unlock()
+		<-f.done
+		return f.data, f.mimeType, f.err
+	}
+	f := &variantFlight{done: make(chan struct{})}
+	s.flights[cacheKey] = f
+	s.flightMu.Unlock()
+
+	// 生成过程 panic 时等待者也能返回
+	f.err = fmt.Errorf("failed to generate variant")
+	defer func() {
+		s.flightMu.Lock()
+		delete(s.flights, cacheKey)
+		s.flightMu.Unlock()
+		close(f.done)
+	}()
+	f.data, f.mimeType, f.err = s.generate(key, cacheKey, opts)
+	return f.data, f.mimeType, f.err
+}
+
+// generate 读取原图生成变换结果并写入缓存
+func (s *TransformService) generate(key, cacheKey string, opts TransformOptions) ([]byte, string, error) {
+	rc, err := R2.OpenFile(key)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rc.Close()
+	src, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read original: %v", err)
+	}
+
+	data, mimeType, err := TransformImage(src, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.cache.Put(cacheKey, data); err != nil {
+		log.Printf("transform cache write failed: %v", err)
+	}
+	return data, mimeType, nil
+}
+
+// variantCacheKey 缓存文件名：原图 key 与参数的 SHA-256
+func variantCacheKey(key string, o TransformOptions) string {
+	raw := fmt.Sprintf("%s|w=%d|h=%d|fit=%s|fmt=%s|q=%d", key, o.Width, o.Height, o.Fit, o.Format, o.Quality)
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// VariantTag 变换参数的短标识，用于区分同一图片不同变换结果的 ETag
+func VariantTag(o TransformOptions) string {
+	return variantCacheKey("", o)[:16]
+}
+
+// TransformImage 解码、缩放/裁剪并重新编码图片
+func TransformImage(src []byte, opts TransformOptions) ([]byte, string, error) {
+	opts = opts.normalize()
+	img, format, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %v", err)
+	}
+
+	switch {
+	case opts.Width == 0 && opts.Height == 0:
+	case opts.Fit == "cover":
+		img = imaging.Fill(img, opts.Width, opts.Height, imaging.Center, imaging.Lanczos)
+	case opts.Fit == "fill":
+		img = imaging.Resize(img, opts.Width, opts.Height, imaging.Lanczos)
+	case opts.Width == 0 || opts.Height == 0:
+		// 只指定一边时按比例缩放，不放大
+		b := img.Bounds()
+		if (opts.Width > 0 && opts.Width < b.Dx()) || (opts.Height > 0 && opts.Height < b.Dy()) {
+			img = imaging.Resize(img, opts.Width, opts.Height, imaging.Lanczos)
+		}
+	default:
+		img = imaging.Fit(img, opts.Width, opts.Height, imaging.Lanczos)
+	}
+
+	outFormat := opts.Format
+	if outFormat == "" {
+		outFormat = format
+	}
+
+	var buf bytes.Buffer
+	switch outFormat {
+	case "png":
+		err = imaging.Encode(&buf, img, imaging.PNG)
+	case "gif":
+		err = imaging.Encode(&buf, img, imaging.GIF)
+	case "webp":
+		err = nativewebp.Encode(&buf, img, nil)
+	default:
+		outFormat = "jpeg"
+		err = imaging.Encode(&buf, img, imaging.JPEG, imaging.JPEGQuality(opts.Quality))
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode image: %v", err)
+	}
+	return buf.Bytes(), "image/" + outFormat, nil
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"io"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func testTransformService(t *testing.T) *TransformService {
+	presets, err := parseTransformPresets("thumb:w=300&h=300&fit=cover;small:w=640;webp:w=64&fmt=webp")
+	if err != nil {
+		t.Fatalf("parseTransformPresets: %v", err)
+	}
+	cache, err := NewVariantCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &TransformService{presets: presets, cache: cache, flights: map[string]*variantFlight{}}
+}
+
+func TestTransformResolve(t *testing.T) {
+	s := testTransformService(t)
+	cases := []struct {
+		query string
+		want  TransformOptions
+		err   error
+	}{
+		{"preset=thumb", TransformOptions{Width: 300, Height: 300, Fit: "cover", Quality: 85}, nil},
+		{"w=640", TransformOptions{Width: 640, Fit: "contain", Quality: 85}, nil},
+		// 与预设等价（显式写出默认值）
+		{"w=640&fit=contain&q=85", TransformOptions{Width: 640, Fit: "contain", Quality: 85}, nil},
+		{"w=64&fmt=webp", TransformOptions{Width: 64, Fit: "contain", Format: "webp", Quality: 85}, nil},
+		{"", TransformOptions{}, nil},
+		// 合法参数但不在白名单内
+		{"preset=huge", TransformOptions{}, ErrTransformNotAllowed},
+		{"w=641", TransformOptions{}, ErrTransformNotAllowed},
+		{"w=640&q=90", TransformOptions{}, ErrTransformNotAllowed},
+		{"w=640&fmt=png", TransformOptions{}, ErrTransformNotAllowed},
+		{"w=300&h=300", TransformOptions{}, ErrTransformNotAllowed},
+	}
+	for _, tc := range cases {
+		values, _ := url.ParseQuery(tc.query)
+		got, err := s.Resolve(values)
+		if !errors.Is(err, tc.err) || (err == nil && got != tc.want) {
+			t.Errorf("Resolve(%q) = %+v, %v; want %+v, %v", tc.query, got, err, tc.want, tc.err)
+		}
+	}
+
+	// 非法参数直接报错
+	for _, q := range []string{"w=0", "w=5000", "w=abc", "fit=stretch&w=1&h=1", "fmt=bmp", "q=101", "fit=cover&w=10"} {
+		values, _ := url.ParseQuery(q)
+		if _, err := s.Resolve(values); err == nil || errors.Is(err, ErrTransformNotAllowed) {
+			t.Errorf("Resolve(%q): err = %v, want validation error", q, err)
+		}
+	}
+
+	if _, err := parseTransformPresets("bad:w=0"); err == nil {
+		t.Error("parseTransformPresets accepted an invalid preset")
+	}
+}
+
+// countingBackend 统计原图读取次数，首次读取时阻塞直到 release 关闭
+type countingBackend struct {
+	StorageBackend
+	gets    atomic.Int32
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (b *countingBackend) Get(key string) (io.ReadCloser, error) {
+	b.gets.Add(1)
+	b.once.Do(func() { close(b.started) })
+	<-b.release
+	return b.StorageBackend.Get(key)
+}
+
+func TestTransformVariantCoalescesMisses(t *testing.T) {
+	local, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testPixels()); err != nil {
+		t.Fatal(err)
+	}
+	if err := local.Put("images/a.png", buf.Bytes(), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	backend := &countingBackend{StorageBackend: local, started: make(chan struct{}), release: make(chan struct{})}
+	old := R2
+	R2 = NewR2Service(backend)
+	t.Cleanup(func() { R2 = old })
+
+	s := testTransformService(t)
+	opts := s.presets["small"]
+
+	const n = 16
+	results := make([][]byte, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, errs[i] = s.Variant("images/a.png", opts)
+		}(i)
+	}
+	// 第一个请求开始生成后放行；其余请求要么等待同一次生成，要么命中其写入的缓存
+	<-backend.started
+	close(backend.release)
+	wg.Wait()
+
+	if got := backend.gets.Load(); got != 1 {
+		t.Fatalf("original read %d times for %d concurrent misses, want 1", got, n)
+	}
+	for i := 0; i < n; i++ {
+		if errs[i] != nil || len(results[i]) == 0 || !bytes.Equal(results[i], results[0]) {
+			t.Fatalf("request %d: err = %v, %d bytes", i, errs[i], len(results[i]))
+		}
+	}
+	if len(s.flights) != 0 {
+		t.Fatalf("%d flights left", len(s.flights))
+	}
+
+	// 生成失败不会缓存，后续请求重新生成
+	if _, _, err := s.Variant("images/missing.png", opts); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("missing original: err = %v", err)
+	}
+	if _, _, err := s.Variant("images/missing.png", opts); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("missing original again: err = %v", err)
+	}
+	if got := backend.gets.Load(); got != 3 {
+		t.Fatalf("original read %d times, want 3", got)
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// VariantCache 变换结果的磁盘缓存，超过容量上限时按 LRU 淘汰
+type VariantCache struct {
+	dir      string
+	maxBytes int64
+
+	mu    sync.Mutex
+	size  int64
+	order *list.List               // 队首为最近使用
+	items map[string]*list.Element // key -> *variantEntry
+}
+
+type variantEntry struct {
+	key  string
+	size int64
+}
+
+// NewVariantCache 创建缓存并载入目录中已有的文件（按修改时间恢复使用顺序）
+func NewVariantCache(dir string, maxBytes int64) (*VariantCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	vc := &VariantCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %v", err)
+	}
+	var infos []os.FileInfo
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		// 上次退出时未完成写入的临时文件
+		if filepath.Ext(e.Name()) == ".tmp" {
+			_ = os.Remove(filepath.Join(dir, e.Name()))
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ModTime().After(infos[j].ModTime()) })
+	for _, info := range infos {
+		el := vc.order.PushBack(&variantEntry{key: info.Name(), size: info.Size()})
+		vc.items[info.Name()] = el
+		vc.size += info.Size()
+	}
+
+	vc.mu.Lock()
+	vc.evictLocked()
+	vc.mu.Unlock()
+	return vc, nil
+}
+
+// Get 读取缓存，命中时标记为最近使用
+func (vc *VariantCache) Get(key string) ([]byte, bool) {
+	vc.mu.Lock()
+	el, ok := vc.items[key]
+	if ok {
+		vc.order.MoveToFront(el)
+	}
+	vc.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(filepath.Join(vc.dir, key))
+	if err != nil {
+		vc.remove(key)
+		return nil, false
+	}
+	return data, true
+}
+
+// Put 写入缓存（先写入独立的临时文件再重命名，并发写入同一 key 互不干扰），随后淘汰超出容量的旧条目
+func (vc *VariantCache) Put(key string, data []byte) error {
+	size := int64(len(data))
+	if vc.maxBytes > 0 && size > vc.maxBytes {
+		return nil
+	}
+
+	f, err := os.CreateTemp(vc.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache file: %v", err)
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(vc.dir, key))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write cache file: %v", err)
+	}
+
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	if el, ok := vc.items[key]; ok {
+		entry := el.Value.(*variantEntry)
+		vc.size += size - entry.size
+		entry.size = size
+		vc.order.MoveToFront(el)
+	} else {
+		vc.items[key] = vc.order.PushFront(&variantEntry{key: key, size: size})
+		vc.size += size
+	}
+	vc.evictLocked()
+	return nil
+}
+
+// Size 当前缓存占用字节数
+func (vc *VariantCache) Size() int64 {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	return vc.size
+}
+
+func (vc *VariantCache) remove(key string) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	if el, ok := vc.items[key]; ok {
+		vc.order.Remove(el)
+		delete(vc.items, key)
+		vc.size -= el.Value.(*variantEntry).size
+	}
+}
+
+// evictLocked 从队尾淘汰直至不超过容量上限，调用方需持有锁
+func (vc *VariantCache) evictLocked() {
+	if vc.maxBytes <= 0 {
+		return
+	}
+	for vc.size > vc.maxBytes {
+		el := vc.order.Back()
+		if el == nil {
+			return
+		}
+		entry := el.Value.(*variantEntry)
+		vc.order.Remove(el)
+		delete(vc.items, entry.key)
+		vc.size -= entry.size
+		_ = os.Remove(filepath.Join(vc.dir, entry.key))
+	}
+}
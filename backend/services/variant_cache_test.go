@@ -0,0 +1,154 @@
+package services
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestVariantCacheLRU(t *testing.T) {
+	vc, err := NewVariantCache(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b"} {
+		if err := vc.Put(key, []byte("1234")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vc.Get("a") // a 成为最近使用，写入 c 时淘汰 b
+	if err := vc.Put("c", []byte("1234")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := vc.Get("b"); ok {
+		t.Fatal("b should have been evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if data, ok := vc.Get(key); !ok || string(data) != "1234" {
+			t.Fatalf("Get(%s) = %q %v", key, data, ok)
+		}
+	}
+	if vc.Size() != 8 {
+		t.Fatalf("Size = %d, want 8", vc.Size())
+	}
+
+	// 超过容量的单个条目不缓存
+	if err := vc.Put("big", make([]byte, 11)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := vc.Get("big"); ok {
+		t.Fatal("oversized entry cached")
+	}
+}
+
+func TestVariantCacheReload(t *testing.T) {
+	dir := t.TempDir()
+	vc, err := NewVariantCache(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vc.Put("a", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟上次退出时未完成的写入
+	if err := os.WriteFile(filepath.Join(dir, "b.123.tmp"), []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	vc, err = NewVariantCache(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := vc.Get("a"); !ok || string(data) != "data" {
+		t.Fatalf("Get(a) after reload = %q %v", data, ok)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.123.tmp")); !os.IsNotExist(err) {
+		t.Fatalf("leftover temp file not removed: %v", err)
+	}
+	if vc.Size() != 4 {
+		t.Fatalf("Size = %d, want 4 (temp file must not be counted)", vc.Size())
+	}
+}
+
+// TestVariantCacheConcurrentPut 并发写入同一 key 时，读者只能看到某次完整写入的内容
+func TestVariantCacheConcurrentPut(t *testing.T) {
+	dir := t.TempDir()
+	vc, err := NewVariantCache(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads := [][]byte{
+		bytes.Repeat([]byte("a"), 256<<10),
+		bytes.Repeat([]byte("b"), 512<<10),
+	}
+	complete := func(data []byte) bool {
+		return bytes.Equal(data, payloads[0]) || bytes.Equal(data, payloads[1])
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	var torn atomic.Bool
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				data, ok := vc.Get("key")
+				if !ok {
+					// 尚未写入或读取时恰被替换，直接读取文件同样不能读到部分内容
+					data, _ = os.ReadFile(filepath.Join(dir, "key"))
+					if data == nil {
+						continue
+					}
+				}
+				if !complete(data) {
+					torn.Store(true)
+					return
+				}
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		writers.Add(1)
+		go func(i int) {
+			defer writers.Done()
+			for j := 0; j < 10; j++ {
+				if err := vc.Put("key", payloads[(i+j)%2]); err != nil {
+					t.Errorf("Put: %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+	writers.Wait()
+	close(stop)
+	wg.Wait()
+	if torn.Load() {
+		t.Fatal("reader observed a partially written cache file")
+	}
+
+	data, ok := vc.Get("key")
+	if !ok || !complete(data) {
+		t.Fatalf("final content: %d bytes, ok = %v", len(data), ok)
+	}
+	if vc.Size() != int64(len(data)) {
+		t.Fatalf("Size = %d, want %d", vc.Size(), len(data))
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			t.Fatalf("temp file left behind: %s", e.Name())
+		}
+	}
+}
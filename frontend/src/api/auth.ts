@@ -6,7 +6,7 @@ const api = axios.create({
   headers: { 'Content-Type': 'application/json' }
 })
 
-export async function login(username: string, password: string): Promise<{ success: boolean; data?: { token: string; username: string; expires: number }; error?: string }> {
+export async function login(username: string, password: string): Promise<{ success: boolean; data?: { token: string; username: string; role?: string; expires: number }; error?: string }> {
   try {
     const { data } = await api.post('/auth/login', { username, password })
     return data
@@ -25,7 +25,7 @@ export async function changePassword(token: string, old_password: string, new_pa
   return data
 }
 
-export async function guestLogin(code: string): Promise<{ success: boolean; data?: { token: string; username: string; expires: number }; error?: string }> {
+export async function guestLogin(code: string): Promise<{ success: boolean; data?: { token: string; username: string; role?: string; expires: number }; error?: string }> {
   try {
     const { data } = await api.post('/auth/guest-login', { code })
     return data
@@ -34,7 +34,7 @@ export async function guestLogin(code: string): Promise<{ success: boolean; data
   }
 }
 
-export async function createGuestCode(token: string, payload: { days?: number; expires_at?: number; permanent?: boolean }) {
+export async function createGuestCode(token: string, payload: { days?: number; expires_at?: number; permanent?: boolean; label?: string; max_logins?: number; max_uploads?: number; allowed_types?: string; max_file_size?: number; quota_max_bytes?: number; quota_max_images?: number; quota_max_daily?: number }) {
   const { data } = await api.post('/guest-codes/', payload, { headers: { Authorization: `Bearer ${token}` } })
   return data
 }
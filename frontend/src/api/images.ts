@@ -33,6 +33,28 @@ export async function listImages(page = 1, pageSize = 20): Promise<{ success: bo
   }
 }
 
+export interface CursorImages {
+  items: ImageInfo[]
+  page_size: number
+  next_cursor: string
+  prev_cursor: string
+  next: string
+  prev: string
+  total?: number
+}
+
+// 游标分页：cursor 为空字符串时取第一页，翻页期间新上传不会导致重复
+export async function listImagesByCursor(cursor = '', pageSize = 100): Promise<{ success: boolean; data?: CursorImages; error?: string }> {
+  try {
+    const { data } = await api.get<{ success: boolean; data: CursorImages }>(`/images`, {
+      params: { cursor, page_size: pageSize },
+    })
+    return data
+  } catch (e: any) {
+    return { success: false, error: e?.message || '获取图片列表失败' }
+  }
+}
+
 export async function deleteImage(uuid: string): Promise<{ success: boolean; error?: string }> {
   try {
     const { data } = await api.delete<{ success: boolean }>(`/images/${uuid}`)
@@ -40,4 +62,119 @@ export async function deleteImage(uuid: string): Promise<{ success: boolean; err
   } catch (e: any) {
     return { success: false, error: e?.message || '删除失败' }
   }
-}
\ No newline at end of file
+}
+export interface TrashItem extends ImageInfo {
+  deleted_at: string
+  purge_at: string | null
+}
+
+export interface PagedTrash {
+  items: TrashItem[]
+  total: number
+  page: number
+  page_size: number
+}
+
+// 回收站：删除的图片保留至 purge_at，可恢复或彻底删除
+export async function listTrash(page = 1, pageSize = 20): Promise<{ success: boolean; data?: PagedTrash; error?: string }> {
+  try {
+    const { data } = await api.get<{ success: boolean; data: PagedTrash }>(`/trash`, {
+      params: { page, page_size: pageSize },
+    })
+    return data
+  } catch (e: any) {
+    return { success: false, error: e?.message || '获取回收站失败' }
+  }
+}
+
+export async function restoreImage(uuid: string): Promise<{ success: boolean; error?: string }> {
+  try {
+    const { data } = await api.post<{ success: boolean }>(`/trash/${uuid}/restore`)
+    return data
+  } catch (e: any) {
+    return { success: false, error: e?.message || '恢复失败' }
+  }
+}
+
+export async function purgeImage(uuid: string): Promise<{ success: boolean; error?: string }> {
+  try {
+    const { data } = await api.delete<{ success: boolean }>(`/trash/${uuid}`)
+    return data
+  } catch (e: any) {
+    return { success: false, error: e?.message || '彻底删除失败' }
+  }
+}
+
+export interface BulkResult {
+  uuid: string
+  success: boolean
+  code?: string
+  error?: string
+}
+
+export interface BulkRequest {
+  action: 'delete' | 'move_to_album' | 'add_tags' | 'remove_tags' | 'set_visibility' | 'transfer'
+  uuids?: string[]
+  filter?: Record<string, string | number | boolean | string[]>
+  album_id?: number
+  from_album_id?: number
+  tags?: string[]
+  visibility?: string
+  uploader?: string
+}
+
+// 批量操作：逐项返回结果
+export async function bulkImages(req: BulkRequest): Promise<{ success: boolean; data?: { action: string; total: number; succeeded: number; failed: number; results: BulkResult[] }; error?: string }> {
+  try {
+    const { data } = await api.post(`/images/bulk`, req)
+    return data
+  } catch (e: any) {
+    return { success: false, error: e?.response?.data?.error || e?.message || '批量操作失败' }
+  }
+}
+
+// ZIP 导出：params 与列表/搜索的过滤参数相同（如 { album: 1 }）
+export async function exportImages(params: Record<string, string | number> = {}): Promise<{ success: boolean; data?: Blob; error?: string }> {
+  try {
+    const { data } = await api.get<Blob>(`/images/export`, { params, responseType: 'blob', timeout: 0 })
+    return { success: true, data }
+  } catch (e: any) {
+    return { success: false, error: e?.message || '导出失败' }
+  }
+}
+
+export interface ImportEntry {
+  index: number
+  name: string
+  status: 'imported' | 'failed' | 'skipped'
+  code?: string
+  error?: string
+  uuid?: string
+  album?: string
+  tags?: string[]
+}
+
+// 归档导入：ZIP 或 tar.gz，folders 指定目录映射为相册或标签
+export async function importArchive(file: File, options: { folders?: 'none' | 'album' | 'tag'; tags?: string[]; visibility?: string } = {}): Promise<{ success: boolean; data?: { total: number; imported: number; failed: number; skipped: number; entries: ImportEntry[]; aborted?: { error: string; code: string } }; error?: string }> {
+  const form = new FormData()
+  form.append('archive', file)
+  if (options.folders) form.append('folders', options.folders)
+  for (const t of options.tags || []) form.append('tags', t)
+  if (options.visibility) form.append('visibility', options.visibility)
+  try {
+    const { data } = await api.post(`/images/import`, form, { timeout: 0 })
+    return data
+  } catch (e: any) {
+    return { success: false, error: e?.response?.data?.error || e?.message || '导入失败' }
+  }
+}
+
+// 远程地址导入：服务端下载后按单图上传处理
+export async function importFromURL(url: string, options: { tags?: string[]; visibility?: string } = {}): Promise<{ success: boolean; data?: ImageInfo & { source_url: string }; error?: string; code?: string }> {
+  try {
+    const { data } = await api.post(`/images/import-url`, { url, ...options }, { timeout: 60000 })
+    return data
+  } catch (e: any) {
+    return { success: false, error: e?.response?.data?.error || e?.message || '导入失败', code: e?.response?.data?.code }
+  }
+}
@@ -3,19 +3,23 @@ import { ref, computed } from 'vue'
 
 export interface UserInfo {
   username: string
+  role?: string
 }
 
 export const useAuthStore = defineStore('auth', () => {
   const token = ref<string | null>(localStorage.getItem('token'))
-  const user = ref<UserInfo | null>(token.value ? { username: localStorage.getItem('username') || '' } : null)
+  const user = ref<UserInfo | null>(
+    token.value ? { username: localStorage.getItem('username') || '', role: localStorage.getItem('role') || '' } : null
+  )
 
   const isAuthenticated = computed(() => !!token.value)
 
-  function setAuth(t: string, u: string) {
+  function setAuth(t: string, u: string, role = '') {
     token.value = t
-    user.value = { username: u }
+    user.value = { username: u, role }
     localStorage.setItem('token', t)
     localStorage.setItem('username', u)
+    localStorage.setItem('role', role)
   }
 
   function clearAuth() {
@@ -23,6 +27,7 @@ export const useAuthStore = defineStore('auth', () => {
     user.value = null
     localStorage.removeItem('token')
     localStorage.removeItem('username')
+    localStorage.removeItem('role')
   }
 
   return { token, user, isAuthenticated, setAuth, clearAuth }
@@ -12,6 +12,8 @@ export interface ImageInfo {
   width: number
   height: number
   public_url: string
+  thumbnail_url?: string
+  tags?: string[]
   created_at: string
 }
 